@@ -1,52 +1,59 @@
 package api
 
 import (
-	"fmt"
-	"net"
-	"time"
+	"context"
 	"net/http"
+	"time"
 )
 
-// Check whether the user has an active internet connection
-func UserIsConnected() bool {
-	timeout := time.Duration(2 * time.Second)
-
-	conn, err := net.DialTimeout("tcp", "www.google.com:80", timeout)
-	if err != nil {
-		return false
-	}
-
-	defer conn.Close()
-
-	return true
-}
-
-func CheckServerStatus2(pingUrl string) bool {
-	conn, err := net.DialTimeout("tcp", pingUrl+":80", time.Duration(5 * time.Second))
+// Status code of a ping, or an error when the server couldn't be reached at all.
+// Goes through the shared client so HTTPS_PROXY and friends are honoured.
+func ping(ctx context.Context, pingUrl string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingUrl, nil)
 	if err != nil {
-		return false
+		return 0, err
 	}
 
-	defer conn.Close()
-	return true
-}
-
-// Ping and check whether the server is online
-func  CheckServerStatus(pingUrl string) (bool, error) {
-	req, err := http.NewRequest("GET", pingUrl, nil)
-	if err != nil {
-		return false, fmt.Errorf("error creating a request: %s", err.Error())
-	}
-
-	client := &http.Client{}
-
 	res, err := client.Do(req)
 	if err != nil {
-		// Either server is offline or the user has no internet
-		return false, nil
+		logger.Debug("ping failed", "err", err)
+		return 0, err
 	}
 
 	defer res.Body.Close()
-	return true, nil
+	logger.Debug("ping", "status", res.StatusCode)
+	return res.StatusCode, nil
 }
 
+// Keeps pinging with backoff until the server answers or wait runs out.
+// Render's free tier sleeps and can take 30s+ to wake, onRetry runs before each new attempt so callers can show progress.
+// waking is true when the last ping got a 5xx, the server is there but still starting.
+// A server that can't be reached at all is given up on after the retry policy's attempts, there is nothing to wake.
+func WaitForServer(ctx context.Context, pingUrl string, wait time.Duration, onRetry func(attempt int, waking bool)) bool {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	unreachable := 0
+	for attempt := 1; ; attempt++ {
+		status, err := ping(ctx, pingUrl)
+		switch {
+		case err == nil && status < 500:
+			return true
+		case err != nil:
+			unreachable++
+			if unreachable >= retryPolicy.MaxAttempts {
+				return false
+			}
+		default:
+			unreachable = 0
+		}
+
+		if err := sleepCtx(ctx, retryPolicy.Backoff(attempt)); err != nil {
+			return false
+		}
+
+		if onRetry != nil {
+			onRetry(attempt+1, err == nil)
+		}
+	}
+}
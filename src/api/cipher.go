@@ -0,0 +1,47 @@
+package api
+
+// Encrypts entries on the way out and decrypts them on the way back, see secret.EntryCipher.
+// Only Log and Title are sealed, tags go through Tag which may hash them.
+type Cipher interface {
+	Seal(plain string) (string, error)
+	Open(text string) (string, error)
+	Tag(tag string) string
+}
+
+func (journal *JournalDB) sealLog(log *CreateJournalLogReq) error {
+	if journal.Cipher == nil {
+		return nil
+	}
+
+	var err error
+	if log.Log, err = journal.Cipher.Seal(log.Log); err != nil {
+		return err
+	}
+	if log.Title, err = journal.Cipher.Seal(log.Title); err != nil {
+		return err
+	}
+
+	tags := make([]string, len(log.Tags))
+	for i, tag := range log.Tags {
+		tags[i] = journal.Cipher.Tag(tag)
+	}
+	log.Tags = tags
+	return nil
+}
+
+func (journal *JournalDB) openLogs(logs []ReadJournalLogRes) error {
+	if journal.Cipher == nil {
+		return nil
+	}
+
+	for i := range logs {
+		var err error
+		if logs[i].Log, err = journal.Cipher.Open(logs[i].Log); err != nil {
+			return err
+		}
+		if logs[i].Title, err = journal.Cipher.Open(logs[i].Title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
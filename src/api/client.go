@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+type ClientConfig struct {
+	// Time allowed to open the connection, including the TLS handshake
+	ConnectTimeout time.Duration
+	// Time allowed for the whole request, reading the body included.
+	// Render's free tier can take 30s+ to wake up so this needs some slack.
+	Timeout time.Duration
+}
+
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		ConnectTimeout: 10 * time.Second,
+		Timeout:        60 * time.Second,
+	}
+}
+
+// Shared by every request so connections get reused
+var client = NewHTTPClient(DefaultClientConfig())
+
+func NewHTTPClient(config ClientConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   config.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   config.ConnectTimeout,
+		ResponseHeaderTimeout: config.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+	}
+}
+
+// Replace the shared client, zero values keep the defaults
+func Configure(config ClientConfig) {
+	defaults := DefaultClientConfig()
+	if config.ConnectTimeout <= 0 {
+		config.ConnectTimeout = defaults.ConnectTimeout
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	client = NewHTTPClient(config)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kinds of failure. Match with errors.Is(err, api.ErrUnauthorized).
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrBadRequest   = errors.New("bad request")
+	ErrNetwork      = errors.New("network error")
+	ErrServer       = errors.New("server error")
+	ErrDecode       = errors.New("decode error")
+	ErrDecrypt      = errors.New("decrypt error")
+)
+
+// Every api call fails with an *Error. Kind is one of the sentinels above,
+// Err is the underlying cause if there is one (so errors.Is(err, context.Canceled) works too).
+type Error struct {
+	Kind    error
+	Code    int
+	Message string
+	Simple  string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Error %d: %s; %s", e.Code, e.Simple, e.Message)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Error response object from the server
+type serverErrorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Simple  string `json:"simple"`
+}
+
+func networkError(err error) *Error {
+	return &Error{Kind: ErrNetwork, Code: 0, Message: err.Error(), Simple: "Error sending request", Err: err}
+}
+
+func decodeError(err error) *Error {
+	return &Error{Kind: ErrDecode, Code: 0, Message: err.Error(), Simple: "Error unmarshaling data", Err: err}
+}
+
+// End to end encryption failed, most likely the wrong journal passphrase
+func decryptError(err error) *Error {
+	return &Error{Kind: ErrDecrypt, Code: 0, Message: err.Error(), Simple: "Error decrypting data", Err: err}
+}
+
+// Builds the error for a non 2xx response, using the server's error body when it sent one
+func errorFromResponse(res *http.Response) *Error {
+	apiErr := &Error{Code: res.StatusCode, Message: res.Status}
+
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		apiErr.Kind = ErrUnauthorized
+		apiErr.Simple = "Not logged in or the session expired"
+	case res.StatusCode == http.StatusNotFound:
+		apiErr.Kind = ErrNotFound
+		apiErr.Simple = "Not found"
+	case res.StatusCode >= 500:
+		apiErr.Kind = ErrServer
+		apiErr.Simple = "Server error"
+	default:
+		apiErr.Kind = ErrBadRequest
+		apiErr.Simple = "Client error"
+	}
+
+	var body serverErrorBody
+	if err := json.NewDecoder(res.Body).Decode(&body); err == nil {
+		if body.Message != "" {
+			apiErr.Message = body.Message
+		}
+		if body.Simple != "" {
+			apiErr.Simple = body.Simple
+		}
+	}
+
+	return apiErr
+}
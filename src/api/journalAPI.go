@@ -1,22 +1,14 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
-type JournError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Simple  string `json:"simple"`
-}
-
-func (e JournError) Error() string {
-	return fmt.Sprintf("Error %d: %s; %s", e.Code, e.Simple, e.Message)
-}
-
 type LogReqPayload struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -27,193 +19,174 @@ type JournalDB struct {
 	Url      string
 	Username string
 	Token    string
+	// Called when the server rejects the token, returns a fresh one.
+	// The failed request is retried once with it. Nil means no re-login.
+	Reauth func(ctx context.Context) (string, error)
+	// Set for end to end encryption, nil sends entries as they are
+	Cipher Cipher
 }
 
-func (journal *JournalDB) ReadJournalLogs() (*[]ReadJournalLogRes, error) {
-	req, err := http.NewRequest("GET", journal.Url, nil)
-	if err != nil {
-		return nil, JournError{
-			Code:    400,
-			Message: err.Error(),
-			Simple:  "Error Marshalling",
-		}
+// Runs call, and if the token was rejected logs in again and runs it once more
+func (journal *JournalDB) withReauth(ctx context.Context, call func() error) error {
+	err := call()
+	if err == nil || journal.Reauth == nil || !errors.Is(err, ErrUnauthorized) {
+		return err
+	}
+
+	logger.Info("token rejected, logging in again", "username", journal.Username)
+	token, authErr := journal.Reauth(ctx)
+	if authErr != nil {
+		return authErr
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Auth", "Bearer "+journal.Token)
+	journal.Token = token
+	return call()
+}
+
+// Fetches the whole journal in one go
+func (journal *JournalDB) ReadJournalLogs(ctx context.Context) (*[]ReadJournalLogRes, error) {
+	return journal.readLogs(ctx, journal.Url)
+}
+
+// Fetches limit logs starting at offset, newest first.
+// A server that ignores the paging params sends everything, so callers should treat len > limit as the last page.
+func (journal *JournalDB) ReadJournalLogsPage(ctx context.Context, limit int, offset int) (*[]ReadJournalLogRes, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+
+	return journal.readLogs(ctx, journal.Url+"?"+query.Encode())
+}
+
+// Headers every journal request carries
+func (journal *JournalDB) header() http.Header {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Auth", "Bearer "+journal.Token)
+	return header
+}
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+func (journal *JournalDB) readLogs(ctx context.Context, logsUrl string) (*[]ReadJournalLogRes, error) {
+	var logs *[]ReadJournalLogRes
+	err := journal.withReauth(ctx, func() error {
+		var err error
+		logs, err = journal.fetchLogs(ctx, logsUrl)
+		return err
+	})
+	return logs, err
+}
+
+func (journal *JournalDB) fetchLogs(ctx context.Context, logsUrl string) (*[]ReadJournalLogRes, error) {
+	res, err := doWithRetry(ctx, http.MethodGet, logsUrl, nil, journal.header())
 	if err != nil {
-		return nil, JournError{
-			Code:    400,
-			Message: err.Error(),
-			Simple:  "Error sending request",
-		}
+		logger.Error("sending read request", "err", err)
+		return nil, networkError(err)
 	}
 
 	defer res.Body.Close()
 
-	switch {
-	case res.StatusCode >= 200 && res.StatusCode < 300:
-		// Success
-		var journalLogs []ReadJournalLogRes
-
-		if err := json.NewDecoder(res.Body).Decode(&journalLogs); err != nil {
-			return nil, JournError{
-				Code:    400,
-				Message: err.Error(),
-				Simple:  "Error Unmarshallng data",
-			}
-		}
-
-		return &journalLogs, nil
-
-	case res.StatusCode >= 400 && res.StatusCode < 500:
-		var errorRes ServerErrorRes
-		if err := json.NewDecoder(res.Body).Decode(&errorRes); err != nil {
-			return nil, ServerErrorRes{
-				Code:    400,
-				Message: res.Status,
-				Simple:  "Client error.",
-			}
-		}
-
-		return nil, errorRes
-		// Client Error
-		// return nil, JournError{
-		// 	Code:    400,
-		// 	Message: res.Status,
-		// 	Simple:  "Client error",
-		// }
-
-	case res.StatusCode >= 500 && res.StatusCode < 600:
-		// Server Error
-		return nil, JournError{
-			Code:    500,
-			Message: res.Status,
-			Simple:  "Server error",
-		}
-
-	default:
-		return nil, JournError{
-			Code:    0,
-			Message: res.Status,
-			Simple:  "Something went wrong. Idk",
-		}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		logger.Warn("read failed", "status", res.StatusCode)
+		return nil, errorFromResponse(res)
+	}
+
+	var journalLogs []ReadJournalLogRes
+	if err := json.NewDecoder(res.Body).Decode(&journalLogs); err != nil {
+		logger.Error("decoding logs", "err", err)
+		return nil, decodeError(err)
+	}
+
+	if err := journal.openLogs(journalLogs); err != nil {
+		logger.Error("decrypting logs", "err", err)
+		return nil, decryptError(err)
 	}
+
+	logger.Debug("logs read", "count", len(journalLogs))
+
+	return &journalLogs, nil
 }
 
-func (journal *JournalDB) CreateJournalLog(log string, title string, tags *[]string) (*JournalMessage, error) {
-	payload, err := json.Marshal(CreateJournalLogReq{
+func (journal *JournalDB) CreateJournalLog(ctx context.Context, log string, title string, tags *[]string) error {
+	newLog := CreateJournalLogReq{
 		Log:   log,
 		Tags:  *tags,
 		Title: title,
-	})
-	if err != nil {
-		fmt.Println("Error creating data payload")
-		return nil, err
+	}
+	if err := journal.sealLog(&newLog); err != nil {
+		logger.Error("encrypting log", "err", err)
+		return decryptError(err)
 	}
 
-	req, err := http.NewRequest("POST", journal.Url, bytes.NewBuffer(payload))
+	payload, err := json.Marshal(newLog)
 	if err != nil {
-		fmt.Println("Error creating request")
-		return nil, err
+		logger.Error("creating log payload", "err", err)
+		return decodeError(err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Auth", "Bearer "+journal.Token)
+	return journal.withReauth(ctx, func() error {
+		return journal.send(ctx, http.MethodPost, payload, "create")
+	})
+}
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+// Sends a write request, op names it in the logs
+func (journal *JournalDB) send(ctx context.Context, method string, payload []byte, op string) error {
+	res, err := doWithRetry(ctx, method, journal.Url, payload, journal.header())
 	if err != nil {
-		fmt.Println("Error sending request")
-		return nil, err
+		logger.Error("sending "+op+" request", "err", err)
+		return networkError(err)
 	}
 
 	defer res.Body.Close()
 	if res.StatusCode >= 200 && res.StatusCode < 300 {
-		return &JournalMessage{Message: res.Status, Code: res.StatusCode, Simple: "good"}, nil
+		logger.Info(op+" succeeded", "status", res.StatusCode)
+		return nil
 
 	} else {
-		return &JournalMessage{Message: res.Status, Code: res.StatusCode, Simple: "bad"}, nil
+		logger.Warn(op+" failed", "status", res.StatusCode)
+		return errorFromResponse(res)
 	}
 }
 
 // Create a copy of the original log obj and edit that itself. This becomes the new log
-func (journal *JournalDB) UpdateJournalLog(prevLog *ReadJournalLogRes) (*JournalMessage, error) {
-	payload, err := json.Marshal(UpdateLogReq{
-		Log:    prevLog.Log,
-		Tags:   prevLog.Tags,
-		Title:  prevLog.Title,
-		Log_Id: prevLog.Log_Id,
-	})
-	if err != nil {
-		fmt.Println("Error marshalling payload")
-		return nil, err
+func (journal *JournalDB) UpdateJournalLog(ctx context.Context, prevLog *ReadJournalLogRes) error {
+	edited := CreateJournalLogReq{
+		Log:   prevLog.Log,
+		Tags:  prevLog.Tags,
+		Title: prevLog.Title,
 	}
-
-	req, err := http.NewRequest("PUT", journal.Url, bytes.NewBuffer(payload))
-	if err != nil {
-		fmt.Println("Error creating request")
-		return nil, err
+	if err := journal.sealLog(&edited); err != nil {
+		logger.Error("encrypting log", "err", err)
+		return decryptError(err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Auth", "Bearer "+journal.Token)
-
-	client := http.Client{}
-	res, err := client.Do(req)
+	payload, err := json.Marshal(UpdateLogReq{
+		Log:    edited.Log,
+		Tags:   edited.Tags,
+		Title:  edited.Title,
+		Log_Id: prevLog.Log_Id,
+	})
 	if err != nil {
-		fmt.Println("Error sending request")
-		return nil, err
+		logger.Error("marshalling update payload", "err", err)
+		return decodeError(err)
 	}
 
-	defer res.Body.Close()
-
-	if res.StatusCode >= 200 && res.StatusCode < 300 {
-		fmt.Println("Updated Successfully")
-		return &JournalMessage{Message: res.Status, Code: res.StatusCode, Simple: "good"}, nil
-	} else {
-		fmt.Println("Something went wrong")
-		return &JournalMessage{Message: res.Status, Code: res.StatusCode, Simple: "bad"}, nil
-	}
+	return journal.withReauth(ctx, func() error {
+		return journal.send(ctx, http.MethodPut, payload, "update")
+	})
 }
 
-func (journal *JournalDB) DeleteJournalLog(log_id int) (*JournalMessage, error) {
+func (journal *JournalDB) DeleteJournalLog(ctx context.Context, log_id int) error {
 	payload, err := json.Marshal(DeleteJournalLogReq{
 		Log_Id: log_id,
 	})
 	if err != nil {
-		fmt.Println("Error marshalling payload")
-		return nil, err
-	}
-
-	req, err := http.NewRequest("DELETE", journal.Url, bytes.NewBuffer(payload))
-	if err != nil {
-		fmt.Println("Error creating request")
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Auth", "Bearer "+journal.Token)
-
-	client := http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		fmt.Println("Error sending request")
-		return nil, err
+		logger.Error("marshalling delete payload", "err", err)
+		return decodeError(err)
 	}
 
-	defer res.Body.Close()
-
-	if res.StatusCode >= 200 && res.StatusCode < 300 {
-		fmt.Println("Deleted Successfully")
-		return &JournalMessage{Message: res.Status, Code: res.StatusCode, Simple: "good"}, nil
-	} else {
-		fmt.Println("Something went wrong", res.Status)
-		return &JournalMessage{Message: res.Status, Code: res.StatusCode, Simple: "bad"}, nil
-	}
+	return journal.withReauth(ctx, func() error {
+		return journal.send(ctx, http.MethodDelete, payload, "delete")
+	})
 }
 
 // func WriteJournalLog() {
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+)
+
+// The api never prints, it logs here instead. Silent until the app hands over a logger.
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	logger = l
+}
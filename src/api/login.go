@@ -1,7 +1,7 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -18,79 +18,69 @@ type AuthRes struct {
 	Username string `json:"username"`
 }
 
-// Error response object from the server
-type ServerErrorRes struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Simple  string `json:"simple"`
-}
-
-func (e ServerErrorRes) Error() string {
-	return fmt.Sprintf("Error %d: %s; %s", e.Code, e.Simple, e.Message)
-}
-
 // Logs in the user and returns the received token
-func LoginUser(urlEndpoint string, email string, password string) (*AuthRes, error) {
+func LoginUser(ctx context.Context, urlEndpoint string, email string, password string) (*AuthRes, error) {
 	payload, err := json.Marshal(UserAuth{
 		Email:    email,
 		Password: password,
 	})
 	if err != nil {
-		return nil, ServerErrorRes{
-			Code:    400,
-			Message: err.Error(),
-			Simple:  "Error marshaling the data.",
-		}
+		return nil, decodeError(err)
 	}
-	req, err := http.NewRequest("POST", urlEndpoint, bytes.NewBuffer(payload))
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	res, err := doWithRetry(ctx, http.MethodPost, urlEndpoint, payload, header)
 	if err != nil {
-		return nil, ServerErrorRes{
-			Code:    400,
-			Message: err.Error(),
-			Simple:  "Error creating request.",
-		}
+		return nil, networkError(err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, errorFromResponse(res)
+	}
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+	var tokenObj AuthRes
+	if err := json.NewDecoder(res.Body).Decode(&tokenObj); err != nil {
+		return nil, decodeError(err)
+	}
+
+	// Token comes as "Bearer <token>"
+	parts := strings.Split(tokenObj.Token, " ")
+	if len(parts) != 2 {
+		return nil, decodeError(fmt.Errorf("unexpected token format"))
+	}
+
+	tokenObj.Token = parts[1]
+	return &tokenObj, nil
+}
+
+// Asks the server to revoke the token. Not every server has a logout route,
+// revoked is false when this one doesn't and the token just stays valid until it expires.
+func LogoutUser(ctx context.Context, urlEndpoint string, token string) (bool, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Auth", "Bearer "+token)
+
+	res, err := doWithRetry(ctx, http.MethodPost, urlEndpoint, nil, header)
 	if err != nil {
-		return nil, ServerErrorRes{
-			Code:    400,
-			Message: err.Error(),
-			Simple:  "Error sending request.",
-		}
+		return false, networkError(err)
 	}
 
 	defer res.Body.Close()
 
 	switch {
 	case res.StatusCode >= 200 && res.StatusCode < 300:
-		// Success
-		var tokenObj AuthRes
-		if err := json.NewDecoder(res.Body).Decode(&tokenObj); err != nil {
-			return nil, ServerErrorRes{
-				Code:    400,
-				Message: err.Error(),
-				Simple:  "Error unmarshaling response.",
-			}
-		}
-
-		tokenStr := strings.Split(tokenObj.Token, " ")[1]
-		tokenObj.Token = tokenStr
-		return &tokenObj, nil
-
-	default:
-		var errorRes ServerErrorRes
-		if err := json.NewDecoder(res.Body).Decode(&errorRes); err != nil {
-			return nil, ServerErrorRes{
-				Code:    400,
-				Message: err.Error(),
-				Simple:  "Error unmarshaling response.",
-			}
-		}
-
-		return nil, errorRes
+		return true, nil
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented:
+		logger.Info("server has no logout route", "status", res.StatusCode)
+		return false, nil
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		// Already expired, nothing left to revoke
+		return true, nil
 	}
+
+	return false, errorFromResponse(res)
 }
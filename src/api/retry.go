@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+type RetryPolicy struct {
+	// Total tries including the first one, 1 turns retries off
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+var retryPolicy = DefaultRetryPolicy()
+
+// Replace the retry policy, zero values keep the defaults
+func SetRetryPolicy(policy RetryPolicy) {
+	defaults := DefaultRetryPolicy()
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaults.MaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaults.BaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaults.MaxDelay
+	}
+
+	retryPolicy = policy
+}
+
+// Full jitter: a random wait up to base * 2^attempt, capped at MaxDelay
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// Waits for d unless the context ends first
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Whether a response is worth another try.
+// Idempotent calls retry any 5xx. Others only retry the 503 Render sends while the instance
+// is waking. A 502 or 504 can come after the app already saved the entry, retrying could save it twice.
+func shouldRetryStatus(method string, status int) bool {
+	if status == http.StatusServiceUnavailable {
+		return true
+	}
+	return isIdempotent(method) && status >= 500
+}
+
+// Whether the request failed before anything was sent, like a refused connection
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// Sends the request under the retry policy. The body is resent on every attempt.
+// Other methods only retry network errors from before the request went out, a POST may have landed.
+func doWithRetry(ctx context.Context, method string, url string, body []byte, header http.Header) (*http.Response, error) {
+	policy := retryPolicy
+
+	var lastErr error
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepCtx(ctx, policy.Backoff(attempt)); err != nil {
+				return nil, err
+			}
+		}
+
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		logger.Debug("request", "method", method, "url", req.URL.Redacted(), "attempt", attempt+1)
+		res, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil || !(isIdempotent(method) || notSent(err)) {
+				return nil, err
+			}
+			logger.Info("request failed, retrying", "method", method, "attempt", attempt+1, "err", err)
+			continue
+		}
+
+		logger.Debug("response", "method", method, "status", res.StatusCode)
+		if attempt < policy.MaxAttempts-1 && shouldRetryStatus(method, res.StatusCode) {
+			logger.Info("server error, retrying", "method", method, "attempt", attempt+1, "status", res.StatusCode)
+			res.Body.Close()
+			continue
+		}
+
+		return res, nil
+	}
+
+	return nil, lastErr
+}
@@ -1,5 +1,7 @@
 package api
 
+import "time"
+
 type CreateJournalLogReq struct {
 	Log   string   `json:"log"`
 	Tags  []string `json:"tags"`
@@ -25,8 +27,11 @@ type DeleteJournalLogReq struct {
 	Log_Id int `json:"log_id"`
 }
 
-type JournalMessage struct {
-	Message string
-	Code    int
-	Simple  string
+// Parses Created_at into local time. ok is false when the server sent something unparseable.
+func (l ReadJournalLogRes) CreatedTime() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, l.Created_at)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.Local(), true
 }
@@ -5,12 +5,46 @@ import (
 )
 
 type Keymap struct {
-	Up    key.Binding
-	Down  key.Binding
-	Left  key.Binding
-	Right key.Binding
-	Help  key.Binding
-	Quit  key.Binding
+	Up        key.Binding
+	Down      key.Binding
+	Left      key.Binding
+	Right     key.Binding
+	Help      key.Binding
+	Quit      key.Binding
+	NextTab   key.Binding
+	PrevTab   key.Binding
+	ForceQuit key.Binding
+	Refresh   key.Binding
+
+	// Log list
+	Tags        key.Binding
+	FilterScope key.Binding
+	Search      key.Binding
+	NextPage    key.Binding
+	PrevPage    key.Binding
+	GoToStart   key.Binding
+	GoToEnd     key.Binding
+
+	// Fuzzy filter
+	Filter       key.Binding
+	ClearFilter  key.Binding
+	FilterAccept key.Binding
+	FilterCancel key.Binding
+
+	// Search box
+	SearchExit key.Binding
+
+	// Tag panel
+	TagToggle key.Binding
+	TagMode   key.Binding
+	TagClear  key.Binding
+	TagBack   key.Binding
+
+	// Calendar
+	PrevMonth key.Binding
+	NextMonth key.Binding
+	ShowDay   key.Binding
+	ClearDay  key.Binding
 }
 
 func (k Keymap) ShortHelp() []key.Binding {
@@ -19,8 +53,9 @@ func (k Keymap) ShortHelp() []key.Binding {
 
 func (k Keymap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Up, k.Down, k.Left, k.Right}, // first column
-		{k.Help, k.Quit},                // second column
+		{k.Up, k.Down, k.Left, k.Right},   // first column
+		{k.NextTab, k.PrevTab, k.Refresh}, // second column
+		{k.Help, k.Quit},                  // third column
 	}
 }
 
@@ -46,7 +81,103 @@ var Keys = Keymap{
 		key.WithHelp("?", "toggle help"),
 	),
 	Quit: key.NewBinding(
-		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithKeys("q"),
 		key.WithHelp("q", "quit"),
 	),
+	ForceQuit: key.NewBinding(
+		key.WithKeys("ctrl+c"),
+		key.WithHelp("ctrl+c", "force quit"),
+	),
+	NextTab: key.NewBinding(
+		key.WithKeys("right", "l", "n", "tab"),
+		key.WithHelp("tab/n", "next tab"),
+	),
+	PrevTab: key.NewBinding(
+		key.WithKeys("left", "h", "p", "shift+tab"),
+		key.WithHelp("shift+tab/p", "prev tab"),
+	),
+	Refresh: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "refresh"),
+	),
+	Tags: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "tags"),
+	),
+	FilterScope: key.NewBinding(
+		key.WithKeys("f"),
+		key.WithHelp("f", "filter scope"),
+	),
+	NextPage: key.NewBinding(
+		key.WithKeys("pgdown", "d"),
+		key.WithHelp("pgdn/d", "next page"),
+	),
+	PrevPage: key.NewBinding(
+		key.WithKeys("pgup", "u"),
+		key.WithHelp("pgup/u", "prev page"),
+	),
+	GoToStart: key.NewBinding(
+		key.WithKeys("home", "g"),
+		key.WithHelp("g/home", "go to start"),
+	),
+	GoToEnd: key.NewBinding(
+		key.WithKeys("end", "G"),
+		key.WithHelp("G/end", "go to end"),
+	),
+	Filter: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "filter"),
+	),
+	ClearFilter: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "clear filter"),
+	),
+	FilterAccept: key.NewBinding(
+		key.WithKeys("enter", "tab", "shift+tab", "ctrl+k", "up", "ctrl+j", "down"),
+		key.WithHelp("enter", "apply filter"),
+	),
+	FilterCancel: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "cancel"),
+	),
+	Search: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "search"),
+	),
+	SearchExit: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "end search"),
+	),
+	TagToggle: key.NewBinding(
+		key.WithKeys(" ", "enter"),
+		key.WithHelp("space", "toggle tag"),
+	),
+	TagMode: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "and/or"),
+	),
+	TagClear: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "clear tags"),
+	),
+	TagBack: key.NewBinding(
+		key.WithKeys("esc", "t"),
+		key.WithHelp("esc", "back to list"),
+	),
+	PrevMonth: key.NewBinding(
+		key.WithKeys("["),
+		key.WithHelp("[", "prev month"),
+	),
+	NextMonth: key.NewBinding(
+		key.WithKeys("]"),
+		key.WithHelp("]", "next month"),
+	),
+	ShowDay: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "show day"),
+	),
+	ClearDay: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "clear day"),
+	),
 }
@@ -0,0 +1,134 @@
+package bubble_init
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+type namedBinding struct {
+	name    string
+	binding *key.Binding
+}
+
+// Every action that can be rebound from the config, by the name used in the config file
+func (k *Keymap) named() []namedBinding {
+	return []namedBinding{
+		{"up", &k.Up},
+		{"down", &k.Down},
+		{"left", &k.Left},
+		{"right", &k.Right},
+		{"help", &k.Help},
+		{"quit", &k.Quit},
+		{"force_quit", &k.ForceQuit},
+		{"next_tab", &k.NextTab},
+		{"prev_tab", &k.PrevTab},
+		{"refresh", &k.Refresh},
+		{"tags", &k.Tags},
+		{"filter_scope", &k.FilterScope},
+		{"search", &k.Search},
+		{"next_page", &k.NextPage},
+		{"prev_page", &k.PrevPage},
+		{"go_to_start", &k.GoToStart},
+		{"go_to_end", &k.GoToEnd},
+		{"filter", &k.Filter},
+		{"clear_filter", &k.ClearFilter},
+		{"filter_accept", &k.FilterAccept},
+		{"filter_cancel", &k.FilterCancel},
+		{"search_exit", &k.SearchExit},
+		{"tag_toggle", &k.TagToggle},
+		{"tag_mode", &k.TagMode},
+		{"tag_clear", &k.TagClear},
+		{"tag_back", &k.TagBack},
+		{"prev_month", &k.PrevMonth},
+		{"next_month", &k.NextMonth},
+		{"show_day", &k.ShowDay},
+		{"clear_day", &k.ClearDay},
+	}
+}
+
+// Actions that are live at the same time. A key may only appear once per scope.
+// The calendar handles its keys before the tab keys, so it's allowed to shadow them.
+var keyScopes = map[string][]string{
+	"list":      append(listKeys[:len(listKeys):len(listKeys)], "search_exit"),
+	"filtered":  append(listKeys[:len(listKeys):len(listKeys)], "clear_filter"),
+	"filtering": {"force_quit", "filter_accept", "filter_cancel"},
+	"search":    {"force_quit", "search_exit"},
+	"tags":      {"up", "down", "force_quit", "tag_toggle", "tag_mode", "tag_clear", "tag_back"},
+	"calendar":  {"up", "down", "left", "right", "help", "quit", "force_quit", "refresh", "prev_month", "next_month", "show_day", "clear_day"},
+}
+
+// Live on the log list whether or not a filter is applied. esc ends a search when
+// nothing is filtered and clears the filter when something is, so those two share it.
+var listKeys = []string{"up", "down", "help", "quit", "force_quit", "next_tab", "prev_tab", "refresh", "tags", "filter_scope",
+	"search", "next_page", "prev_page", "go_to_start", "go_to_end", "filter"}
+
+// Builds the keymap from the defaults with the overrides from the config applied.
+// Overrides map an action name to the keys that trigger it, e.g. "quit": ["q", "ctrl+q"].
+func LoadKeymap(overrides map[string][]string) (Keymap, error) {
+	keymap := Keys
+
+	byName := make(map[string]*key.Binding)
+	for _, nb := range keymap.named() {
+		byName[nb.name] = nb.binding
+	}
+
+	for name, keys := range overrides {
+		binding, ok := byName[name]
+		if !ok {
+			return keymap, fmt.Errorf("unknown key action %q", name)
+		}
+
+		if len(keys) == 0 {
+			return keymap, fmt.Errorf("key action %q has no keys", name)
+		}
+
+		*binding = key.NewBinding(
+			key.WithKeys(keys...),
+			key.WithHelp(strings.Join(keys, "/"), binding.Help().Desc),
+		)
+	}
+
+	if err := keymap.Validate(); err != nil {
+		return keymap, err
+	}
+
+	return keymap, nil
+}
+
+// Reports keys bound to more than one action in the same scope
+func (k Keymap) Validate() error {
+	byName := make(map[string]key.Binding)
+	for _, nb := range k.named() {
+		byName[nb.name] = *nb.binding
+	}
+
+	var conflicts []string
+
+	scopes := make([]string, 0, len(keyScopes))
+	for scope := range keyScopes {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	for _, scope := range scopes {
+		owner := make(map[string]string)
+		for _, name := range keyScopes[scope] {
+			for _, k := range byName[name].Keys() {
+				if other, taken := owner[k]; taken && other != name {
+					conflicts = append(conflicts, fmt.Sprintf("%q is bound to both %s and %s (%s)", k, other, name, scope))
+					continue
+				}
+				owner[k] = name
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("conflicting key bindings:\n%s", strings.Join(conflicts, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/apooravm/tjournal/src/api"
+	"github.com/apooravm/tjournal/src/fsutil"
+)
+
+var (
+	// Set from main, lives next to the config file
+	CachePath string
+	// Logged in user, set from main. A cache written for someone else is never read back.
+	User string
+)
+
+// Local copy of the journal for offline use, plus logs written while offline that still need sending
+type Cache struct {
+	Logs    []api.ReadJournalLogRes   `json:"logs"`
+	Pending []api.CreateJournalLogReq `json:"pending"`
+	// Last time Logs was updated from the server, a page or the lot
+	FetchedAt time.Time `json:"fetched_at"`
+	// Last time Logs was replaced by the whole journal. Pages only add to what's there,
+	// so until this is set Logs is whatever got scrolled past.
+	SyncedAt time.Time `json:"synced_at,omitempty"`
+	// Logs were written since the last full fetch, so Logs is missing some
+	Stale bool `json:"stale,omitempty"`
+	// Username the logs and queue belong to. Empty in caches from before it was recorded.
+	Owner string `json:"owner,omitempty"`
+}
+
+// Reads the cache. A missing file is an empty cache, not an error.
+func Load() (*Cache, error) {
+	var c Cache
+
+	byteArr, err := os.ReadFile(CachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return &c, nil
+	}
+	if err != nil {
+		return &c, err
+	}
+
+	if err := json.Unmarshal(byteArr, &c); err != nil {
+		return &c, err
+	}
+
+	// Another account's journal and queue, it's overwritten on the next write
+	if User != "" && c.Owner != "" && c.Owner != User {
+		return &Cache{}, nil
+	}
+
+	return &c, nil
+}
+
+// Removes the cache and its backup, on logout or delete. It's the whole journal in plain text.
+func Delete() error {
+	lock, err := fsutil.LockFile(CachePath)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	for _, path := range []string{CachePath, CachePath + ".bak"} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) Save() error {
+	lock, err := fsutil.LockFile(CachePath)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return c.write()
+}
+
+// Caller holds the lock
+func (c *Cache) write() error {
+	if User != "" {
+		c.Owner = User
+	}
+	byteArr, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return fsutil.WriteAtomic(CachePath, byteArr, 0600)
+}
+
+// Load, change and save under the lock, so another tjournal running at the same time
+// doesn't lose its queued logs to this one's write. Returns the updated cache.
+func Update(update func(c *Cache)) (*Cache, error) {
+	lock, err := fsutil.LockFile(CachePath)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	c, err := Load()
+	if err != nil {
+		c = &Cache{}
+	}
+
+	update(c)
+	return c, c.write()
+}
+
+// Swap in a full copy of the journal
+func (c *Cache) Replace(logs []api.ReadJournalLogRes) {
+	c.Logs = append([]api.ReadJournalLogRes{}, logs...)
+	c.FetchedAt = time.Now()
+	c.SyncedAt = c.FetchedAt
+	c.Stale = false
+	c.sort()
+}
+
+// Add or update logs from a partial fetch, keeping the rest
+func (c *Cache) Merge(logs []api.ReadJournalLogRes) {
+	index := make(map[int]int)
+	for i, log := range c.Logs {
+		index[log.Log_Id] = i
+	}
+
+	for _, log := range logs {
+		if i, ok := index[log.Log_Id]; ok {
+			c.Logs[i] = log
+			continue
+		}
+		index[log.Log_Id] = len(c.Logs)
+		c.Logs = append(c.Logs, log)
+	}
+
+	c.FetchedAt = time.Now()
+	c.sort()
+}
+
+// Newest first, same as the server
+func (c *Cache) sort() {
+	sort.SliceStable(c.Logs, func(i, j int) bool {
+		return c.Logs[i].Created_at > c.Logs[j].Created_at
+	})
+}
+
+// Queue a log to send once the server is reachable again
+func (c *Cache) Queue(log api.CreateJournalLogReq) {
+	c.Pending = append(c.Pending, log)
+}
+
+// A page of the cached logs, shaped like JournalDB.ReadJournalLogsPage
+func (c *Cache) Page(limit int, offset int) *[]api.ReadJournalLogRes {
+	page := []api.ReadJournalLogRes{}
+	if offset < len(c.Logs) {
+		page = append(page, c.Logs[offset:min(offset+limit, len(c.Logs))]...)
+	}
+	return &page
+}
+
+// Helpers for callers that only want to touch the cache once
+
+func StoreAll(logs []api.ReadJournalLogRes) error {
+	_, err := Update(func(c *Cache) { c.Replace(logs) })
+	return err
+}
+
+func StorePage(logs []api.ReadJournalLogRes) error {
+	_, err := Update(func(c *Cache) { c.Merge(logs) })
+	return err
+}
+
+// After sending a new log, which only the server has until the next full fetch
+func MarkStale() error {
+	_, err := Update(func(c *Cache) { c.Stale = true })
+	return err
+}
+
+// Whether Logs was the whole journal as of SyncedAt, with nothing written since
+func (c *Cache) Complete() bool {
+	return !c.SyncedAt.IsZero() && !c.Stale
+}
+
+// Whether Logs can stand in for the server: the whole journal, synced within maxAge
+func (c *Cache) Fresh(maxAge time.Duration) bool {
+	return c.Complete() && time.Since(c.SyncedAt) < maxAge
+}
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/apooravm/tjournal/src/api"
+	"github.com/apooravm/tjournal/src/theme"
+	"github.com/charmbracelet/lipgloss"
 )
 
 func handleCLIArg(cliArg string) {
@@ -27,30 +31,13 @@ func handleCLIArg(cliArg string) {
 	}
 }
 
-// Print colored error text
+// Print colored error text. Colour names map onto the current theme, NO_COLOR turns them off.
 func LogColourSprintf(message string, colour string) string {
-	switch colour {
-	case "red":
-		return fmt.Sprintf("\x1b[31m%s\x1b[0m", message)
-
-	case "yellow":
-		return fmt.Sprintf("\x1b[33m%s\x1b[0m", message)
-
-	case "green":
-		return fmt.Sprintf("\x1b[32m%s\x1b[0m", message)
-
-	case "magenta":
-		return fmt.Sprintf("\x1b[35m%s\x1b[0m", message)
-
-	case "cyan":
-		return fmt.Sprintf("\x1b[36m%s\x1b[0m", message)
-
-	case "blue":
-		return fmt.Sprintf("\x1b[34m%s\x1b[0m", message)
-
-	default:
-		return fmt.Sprintf("%s", message)
+	if theme.NoColor() {
+		return message
 	}
+
+	return lipgloss.NewStyle().Foreground(theme.Current().Named(colour)).Render(message)
 }
 
 func LogColourPrint(message string, colour string) {
@@ -63,33 +50,124 @@ func CreateConfigIfNotExist(configName string) {
 	}
 }
 
-func ConfigBusiness(configName string, loginEndpoint string) (*LocalConfig, error) {
+func ConfigBusiness(ctx context.Context, configName string, loginEndpoint string) (*LocalConfig, error) {
 	if ConfigFileExists() {
 		config, err := ReadConfig()
 		if err != nil {
 			return nil, fmt.Errorf("%s\n", "Error reading config file. "+err.Error())
 		}
-		return config, nil
+		if config.LoggedIn() {
+			return config, nil
+		}
+		// Logged out, settings are kept but a new login is needed
+	}
 
-	} else {
-		email, password := ScanUsernamePassword()
-		token, err := api.LoginUser(loginEndpoint, email, password)
+	email, password := ScanUsernamePassword()
+	return Login(ctx, loginEndpoint, email, password)
+}
+
+// Logs in and saves the token. An existing config keeps its settings, only the credentials change.
+func Login(ctx context.Context, loginEndpoint string, email string, password string) (*LocalConfig, error) {
+	if email == "" || password == "" {
+		return nil, fmt.Errorf("%s\n", "Need an email and password to log in")
+	}
+
+	auth, err := api.LoginUser(ctx, loginEndpoint, email, password)
+	if err != nil {
+		// Wrapped so callers can still errors.Is the api error kind
+		if errors.Is(err, api.ErrUnauthorized) {
+			return nil, fmt.Errorf("Wrong email or password. %w\n", err)
+		}
+		return nil, fmt.Errorf("%w\n", err)
+	}
 
+	if !ConfigFileExists() {
+		config, err := CreateConfigFile(auth.Token, auth.Username, email)
 		if err != nil {
-			// Already know its going to be of type ServerErrorRes thus dont need to check with ok.
-			serverErr, _ := err.(api.ServerErrorRes)
-			return nil, fmt.Errorf("%s\n", fmt.Sprintf("%d %s %s", serverErr.Code, serverErr.Message, serverErr.Simple))
+			return nil, fmt.Errorf("%s\n", "Error creating config... "+err.Error())
 		}
+		return config, nil
+	}
+
+	var config *LocalConfig
+	err = UpdateConfig(func(c *LocalConfig) error {
+		c.Token = auth.Token
+		c.Username = auth.Username
+		c.Email = email
+		config = c
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s\n", "Error saving config. "+err.Error())
+	}
 
-		if err := CreateConfigFile(token.Token, token.Username); err != nil {
-			return nil, fmt.Errorf("%s\n", "Error creating config...")
+	return config, nil
+}
+
+// Revokes the token when the server can, then wipes the credentials from the config.
+// Settings stay. Offline the token is only wiped locally.
+func Logout(ctx context.Context, logoutEndpoint string, online bool) error {
+	if !ConfigFileExists() {
+		return errors.New("Not logged in")
+	}
+
+	config, err := ReadConfig()
+	if err != nil {
+		return fmt.Errorf("Error reading config file. %s", err.Error())
+	}
+	if !config.LoggedIn() {
+		return errors.New("Not logged in")
+	}
+
+	if online {
+		revoked := false
+		var err error
+		if config.Token == "" {
+			err = config.LoadToken()
+		}
+		if err == nil {
+			revoked, err = api.LogoutUser(ctx, logoutEndpoint, config.Token)
+		}
+		switch {
+		case err != nil:
+			LogColourPrint("Couldn't revoke the token on the server, removing it locally: "+err.Error(), "yellow")
+		case !revoked:
+			LogColourPrint("Server can't revoke tokens, it stays valid until it expires", "yellow")
+		}
+	} else {
+		LogColourPrint("Offline, the token is only removed locally", "yellow")
+	}
+
+	config.Username = ""
+	config.Email = ""
+	return config.ForgetToken()
+}
+
+// Re-login hook for api.JournalDB.Reauth. Asks for the password again, only the password
+// if the email was saved, and writes the new token into the config.
+func Reauthenticator(config *LocalConfig, loginEndpoint string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		LogColourPrint("Your session has expired, log in again", "yellow")
+
+		email := config.Email
+		var password string
+		if email == "" {
+			email, password = ScanUsernamePassword()
+		} else {
+			password = ScanPassword(email)
+		}
+		if password == "" {
+			return "", errors.New("login cancelled")
 		}
 
-		config, err := ReadConfig()
+		fresh, err := Login(ctx, loginEndpoint, email, password)
 		if err != nil {
-			return nil, fmt.Errorf("%s\n", "Error readinf config file...")
+			return "", err
 		}
 
-		return config, nil
+		config.Token = fresh.Token
+		config.Username = fresh.Username
+		config.Email = fresh.Email
+		return fresh.Token, nil
 	}
 }
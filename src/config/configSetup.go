@@ -1,22 +1,100 @@
 package config
 
 import (
-	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/apooravm/tjournal/src/api"
+	"github.com/apooravm/tjournal/src/fsutil"
+	"github.com/apooravm/tjournal/src/secret"
+	"github.com/apooravm/tjournal/src/theme"
+	"golang.org/x/term"
 )
 
 // Converting to a global module var that can be assigned from configBusiness.go
 var (
 	ConfigPath string
+	// Where the token lives, out of the config json. Nil keeps it in the config in plain text.
+	Secrets secret.Store
 )
 
 type LocalConfig struct {
-	Token    string   `json:"token"`
-	Username string   `json:"username"`
-	Logs     []string `json:"logs"`
+	// Format of the file, see ConfigVersion and migrations
+	Version int `json:"version"`
+	// Only in the json for configs from before the secret store, moved out on first use
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username"`
+	// Kept so an expired session only asks for the password
+	Email string `json:"email,omitempty"`
+	// TUI key overrides, action name to keys. Eg. "quit": ["q", "ctrl+q"]
+	Keys map[string][]string `json:"keys,omitempty"`
+	// Builtin or custom theme name, empty picks dark or light from the terminal
+	Theme  string                 `json:"theme,omitempty"`
+	Themes map[string]theme.Theme `json:"themes,omitempty"`
+	// Seconds between automatic refreshes in the TUI, 0 is off
+	RefreshInterval int `json:"refresh_interval,omitempty"`
+	// Logs the TUI fetches per request
+	PageSize int `json:"page_size,omitempty"`
+	// Seconds allowed to connect and for a whole request. 0 keeps the defaults.
+	ConnectTimeout int `json:"connect_timeout,omitempty"`
+	RequestTimeout int `json:"request_timeout,omitempty"`
+	// Retries for failed requests. 0 keeps the defaults.
+	RetryAttempts    int `json:"retry_attempts,omitempty"`
+	RetryBaseDelayMs int `json:"retry_base_delay_ms,omitempty"`
+	RetryMaxDelayMs  int `json:"retry_max_delay_ms,omitempty"`
+	// Seconds to keep pinging a sleeping server before giving up
+	WakeTimeout int `json:"wake_timeout,omitempty"`
+	// Where to write the app's logs, overridden by --log-file
+	LogFile string `json:"log_file,omitempty"`
+	// End to end encrypt each log's text and title, the server only stores ciphertext
+	E2E bool `json:"e2e,omitempty"`
+	// "hash" sends tags as keyed hashes, anything else sends them as they are
+	E2ETags string `json:"e2e_tags,omitempty"`
+	// Known text sealed with the journal key, catches a mistyped passphrase before anything is written with it
+	E2ECheck string `json:"e2e_check,omitempty"`
+	// Server to use instead of the default one, eg. "http://localhost:4000"
+	Server string `json:"server,omitempty"`
+	// Tags for logs saved with `tjournal new`, "quick" when empty
+	DefaultTags []string `json:"default_tags,omitempty"`
+	// IANA name like "Europe/Berlin" for showing times, empty uses the system's
+	Timezone string `json:"timezone,omitempty"`
+	// Command for `config edit`, falls back on $VISUAL then $EDITOR
+	Editor string `json:"editor,omitempty"`
+	// "text" or "json", how recent and stats print
+	Output string `json:"output,omitempty"`
+	// Questions `tjournal daily` walks through, in order
+	DailyQuestions []string `json:"daily_questions,omitempty"`
+
+	// Token as it is in the secret store, so saving an unchanged one doesn't ask for the passphrase
+	storedToken string
+}
+
+func (c *LocalConfig) ClientConfig() api.ClientConfig {
+	return api.ClientConfig{
+		ConnectTimeout: time.Duration(c.ConnectTimeout) * time.Second,
+		Timeout:        time.Duration(c.RequestTimeout) * time.Second,
+	}
+}
+
+// The configured daily questions, or some to start with
+func (c *LocalConfig) DailyPrompts() []string {
+	if len(c.DailyQuestions) > 0 {
+		return c.DailyQuestions
+	}
+	return []string{"What did you ship?", "Blockers?", "Mood 1-5"}
+}
+
+func (c *LocalConfig) RetryPolicy() api.RetryPolicy {
+	return api.RetryPolicy{
+		MaxAttempts: c.RetryAttempts,
+		BaseDelay:   time.Duration(c.RetryBaseDelayMs) * time.Millisecond,
+		MaxDelay:    time.Duration(c.RetryMaxDelayMs) * time.Millisecond,
+	}
 }
 
 func ConfigFileExists() bool {
@@ -26,99 +104,258 @@ func ConfigFileExists() bool {
 	return true
 }
 
-func CreateConfigFile(token string, username string) error {
-	configInit := LocalConfig{
+func CreateConfigFile(token string, username string, email string) (*LocalConfig, error) {
+	config := &LocalConfig{
 		Token:    token,
 		Username: username,
-		Logs:     make([]string, 0),
+		Email:    email,
 	}
+	return config, SaveConfig(config)
+}
 
-	file, err := os.Create(ConfigPath)
+// Writes the whole config back, settings included. The token goes to the secret store.
+func SaveConfig(config *LocalConfig) error {
+	lock, err := fsutil.LockFile(ConfigPath)
 	if err != nil {
 		return err
 	}
+	defer lock.Unlock()
 
-	jsonData, err := json.MarshalIndent(&configInit, "", "    ")
+	return writeConfig(config)
+}
+
+// Read, change and write the config under the lock, so runs from hooks or cron
+// at the same time as the TUI don't overwrite each other's changes
+func UpdateConfig(update func(config *LocalConfig) error) error {
+	lock, err := fsutil.LockFile(ConfigPath)
 	if err != nil {
 		return err
 	}
+	defer lock.Unlock()
 
-	defer file.Close()
-
-	_, err = file.Write(jsonData)
+	config, err := ReadConfig()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := update(config); err != nil {
+		return err
+	}
+
+	return writeConfig(config)
 }
 
-func ReadConfig() (*LocalConfig, error) {
-	var localConfig LocalConfig
+// Caller holds the lock
+func writeConfig(config *LocalConfig) error {
+	config.Version = ConfigVersion
+	onDisk := *config
+	if Secrets != nil {
+		if config.Token != "" && config.Token != config.storedToken {
+			if err := Secrets.Save(config.Token); err != nil {
+				return fmt.Errorf("saving token: %w", err)
+			}
+			config.storedToken = config.Token
+		}
+		onDisk.Token = ""
+	}
 
-	file, err := os.Open(ConfigPath)
+	jsonData, err := json.MarshalIndent(&onDisk, "", "    ")
 	if err != nil {
-		return &localConfig, err
+		return err
 	}
 
-	defer file.Close()
+	if err := fsutil.WriteAtomic(ConfigPath, jsonData, 0600); err != nil {
+		return err
+	}
+	if onDisk.Token == "" {
+		return scrubBackup(jsonData)
+	}
+	return nil
+}
 
-	if err = json.NewDecoder(file).Decode(&localConfig); err != nil {
-		return &localConfig, err
+// The backup is the file from before this write, which can still have a plain text token
+// from an older version. That gets swapped for the new copy, which has none.
+func scrubBackup(current []byte) error {
+	data, err := os.ReadFile(ConfigPath + ".bak")
+	if err != nil {
+		return nil
 	}
 
-	return &localConfig, err
+	// Token is omitempty, so the key alone means there's one. Also catches a damaged file that won't parse.
+	if !bytes.Contains(data, []byte(`"token"`)) {
+		return nil
+	}
+	return os.WriteFile(ConfigPath+".bak", current, 0600)
 }
 
-func DeleteConfigFile() error {
-	if err := os.Remove(ConfigPath); err != nil {
+// Fills in Token from the secret store, may ask for the passphrase.
+// A plain text token left by an older version is moved into the store.
+func (c *LocalConfig) LoadToken() error {
+	if Secrets == nil || (c.Token != "" && c.Token == c.storedToken) {
+		return nil
+	}
+
+	if c.Token != "" {
+		token := c.Token
+		if err := UpdateConfig(func(config *LocalConfig) error {
+			config.Token = token
+			return nil
+		}); err != nil {
+			return err
+		}
+		c.storedToken = token
+		LogColourPrint("Moved the login token out of the config into encrypted storage", "green")
+		return nil
+	}
+
+	token, err := Secrets.Load()
+	if err != nil {
 		return err
 	}
 
+	c.Token = token
+	c.storedToken = token
 	return nil
 }
 
-// Returns scanned email, password
-func ScanUsernamePassword() (string, string) {
-	var email string
-	var pass string
+const e2eCheckText = "tjournal"
 
-	fmt.Println("Enter your registered email: ")
-	scanner := bufio.NewScanner(os.Stdin)
-	if scanner.Scan() {
-		email = scanner.Text()
+// Asks for the journal passphrase and builds the cipher for api.JournalDB.
+// The first time, the passphrase is asked twice and a check value is saved for next time.
+func (c *LocalConfig) EntryCipher() (*secret.EntryCipher, error) {
+	pass, err := JournalPassphrase(c.E2ECheck == "")
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Println("Enter password: ")
-	scanner = bufio.NewScanner(os.Stdin)
-	if scanner.Scan() {
-		pass = scanner.Text()
+	cipher, err := secret.NewEntryCipher(pass, c.Username, c.E2ETags == "hash")
+	if err != nil {
+		return nil, err
 	}
 
-	return email, pass
-}
+	if c.E2ECheck != "" {
+		if text, err := cipher.Open(c.E2ECheck); err != nil || text != e2eCheckText {
+			return nil, secret.ErrDecrypt
+		}
+		return cipher, nil
+	}
 
-func LogAppData(appLog string) error {
-	byteArr, err := os.ReadFile(ConfigPath)
+	check, err := cipher.Seal(e2eCheckText)
+	if err != nil {
+		return nil, err
+	}
+	err = UpdateConfig(func(config *LocalConfig) error {
+		// Another tjournal set one up while the passphrase was being typed, it has to match that one
+		if config.E2ECheck != "" {
+			if text, err := cipher.Open(config.E2ECheck); err != nil || text != e2eCheckText {
+				return secret.ErrDecrypt
+			}
+			check = config.E2ECheck
+			return nil
+		}
+		config.E2ECheck = check
+		return nil
+	})
 	if err != nil {
-		return errors.New("Error reading file" + err.Error())
+		return nil, err
 	}
+	c.E2ECheck = check
+	return cipher, nil
+}
+
+// Whether there's a token, loaded or not
+func (c *LocalConfig) LoggedIn() bool {
+	return c.Token != "" || (Secrets != nil && Secrets.Exists())
+}
 
-	var config LocalConfig
-	if err := json.Unmarshal(byteArr, &config); err != nil {
-		return fmt.Errorf("error unmarshaling config: %s", err.Error())
+// Wipes the token from the config and the secret store
+func (c *LocalConfig) ForgetToken() error {
+	c.Token = ""
+	c.storedToken = ""
+	if Secrets != nil {
+		if err := Secrets.Delete(); err != nil {
+			return err
+		}
 	}
+	return UpdateConfig(func(config *LocalConfig) error {
+		config.Token = ""
+		return nil
+	})
+}
 
-	config.Logs = append(config.Logs, appLog)
+func ReadConfig() (*LocalConfig, error) {
+	localConfig, err := readConfigFile(ConfigPath)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return localConfig, err
+	}
 
-	updatedByteArr, err := json.Marshal(&config)
+	// Unreadable config, fall back on the copy from before the last write
+	backup, bakErr := readConfigFile(ConfigPath + ".bak")
+	if bakErr != nil {
+		return localConfig, err
+	}
+	LogColourPrint("Config file is damaged, using the backup: "+err.Error(), "yellow")
+	return backup, nil
+}
+
+func readConfigFile(path string) (*LocalConfig, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("error marshaling config: %s", err.Error())
+		return &LocalConfig{}, err
+	}
+
+	return decodeConfig(data)
+}
+
+func DeleteConfigFile() error {
+	if err := os.Remove(ConfigPath); err != nil {
+		return err
 	}
+	os.Remove(ConfigPath + ".bak")
 
-	if err := os.WriteFile(ConfigPath, updatedByteArr, os.ModePerm); err != nil {
-		return fmt.Errorf("error writing to file: %s", err.Error())
+	if Secrets != nil {
+		return Secrets.Delete()
 	}
 
 	return nil
 }
+
+// Returns scanned email, password. The password isn't echoed.
+func ScanUsernamePassword() (string, string) {
+	email := ScanEmail()
+	return email, ScanPassword(email)
+}
+
+func ScanEmail() string {
+	fmt.Println("Enter your registered email: ")
+	email, _ := readLine()
+	return strings.TrimSpace(email)
+}
+
+// Asks a free text question on the terminal
+func Ask(question string) (string, error) {
+	fmt.Print(question + " ")
+	answer, err := readLine()
+	return strings.TrimSpace(answer), err
+}
+
+// Yes or no question, defaults to no. Without a terminal to ask on it's always no.
+func Confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+
+	fmt.Print(question + " [y/N] ")
+	answer, _ := readLine()
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func ScanPassword(email string) string {
+	pass, err := readPassword(fmt.Sprintf("Enter password for %s: ", email))
+	if err != nil {
+		LogColourPrint("Error reading password: "+err.Error(), "red")
+		return ""
+	}
+	return pass
+}
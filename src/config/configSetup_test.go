@@ -0,0 +1,53 @@
+package config
+
+import "testing"
+
+// Changes that go through a config read earlier mustn't undo a `config set` made since
+func TestTokenChangesKeepNewerSettings(t *testing.T) {
+	store := tempConfig(t, `{"version": 2, "username": "a", "page_size": 10}`)
+	store.token = "TOKEN"
+	t.Setenv("TJOURNAL_E2E_PASSPHRASE", "journal pass")
+
+	config, err := ReadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	setPageSize := func(size int) {
+		t.Helper()
+		if err := UpdateConfig(func(c *LocalConfig) error { c.PageSize = size; return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(step string, size int) {
+		t.Helper()
+		onDisk, err := ReadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if onDisk.PageSize != size {
+			t.Errorf("after %s page_size = %d, want %d", step, onDisk.PageSize, size)
+		}
+	}
+
+	setPageSize(25)
+	if _, err := config.EntryCipher(); err != nil {
+		t.Fatal(err)
+	}
+	check("EntryCipher", 25)
+	if onDisk, _ := ReadConfig(); onDisk.E2ECheck == "" || onDisk.E2ECheck != config.E2ECheck {
+		t.Errorf("check value on disk %q, in memory %q", onDisk.E2ECheck, config.E2ECheck)
+	}
+
+	setPageSize(30)
+	if err := config.ForgetToken(); err != nil {
+		t.Fatal(err)
+	}
+	check("ForgetToken", 30)
+	if store.token != "" {
+		t.Error("token still stored after ForgetToken")
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// One reader for every prompt, separate scanners would each swallow buffered input
+var stdin = bufio.NewReader(os.Stdin)
+
+// Reads a line from stdin without the trailing newline
+func readLine() (string, error) {
+	line, err := stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Reads a password without echoing it. Piped input has no terminal to hide, it's read as a plain line.
+func readPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readLine()
+	}
+
+	fmt.Print(prompt)
+	pass, err := term.ReadPassword(fd)
+	// ReadPassword eats the enter, put the newline back
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(pass), nil
+}
+
+// For --password-stdin, eg. `cat pass.txt | tjournal login me@mail.com --password-stdin`
+func ReadPasswordStdin() (string, error) {
+	pass, err := readLine()
+	if err != nil {
+		return "", fmt.Errorf("reading password from stdin: %w", err)
+	}
+	return pass, nil
+}
+
+// Passphrase for the token's secret store. TJOURNAL_PASSPHRASE wins, otherwise it's asked for.
+// confirm asks twice, for when a new one is being chosen.
+func Passphrase(confirm bool) (string, error) {
+	return askPassphrase("TJOURNAL_PASSPHRASE", "your login", confirm)
+}
+
+// Passphrase for end to end encryption of the journal. Has to be the same on every device.
+func JournalPassphrase(confirm bool) (string, error) {
+	return askPassphrase("TJOURNAL_E2E_PASSPHRASE", "your journal", confirm)
+}
+
+func askPassphrase(env string, what string, confirm bool) (string, error) {
+	if pass := os.Getenv(env); pass != "" {
+		return pass, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no terminal to ask for the passphrase, set %s", env)
+	}
+
+	if !confirm {
+		return readPassword(fmt.Sprintf("Passphrase to unlock %s: ", what))
+	}
+
+	pass, err := readPassword(fmt.Sprintf("Choose a passphrase to encrypt %s: ", what))
+	if err != nil {
+		return "", err
+	}
+	again, err := readPassword("Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if pass != again {
+		return "", errors.New("passphrases don't match")
+	}
+	return pass, nil
+}
@@ -0,0 +1,382 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apooravm/tjournal/src/bubble_init"
+	"github.com/apooravm/tjournal/src/fsutil"
+	"github.com/apooravm/tjournal/src/theme"
+)
+
+// Bump with every change to LocalConfig that old files need converting for, and add the step to migrations
+const ConfigVersion = 2
+
+// migrations[n] takes a config from version n to n+1. They work on the raw json
+// so renamed or restructured fields can still be read.
+var migrations = []func(raw map[string]json.RawMessage) error{
+	// 0 -> 1: versions start here. Old files could have "logs": null.
+	func(raw map[string]json.RawMessage) error {
+		if logs, ok := raw["logs"]; !ok || string(logs) == "null" {
+			raw["logs"] = json.RawMessage("[]")
+		}
+		return nil
+	},
+	// 1 -> 2: app events moved out to the activity log
+	func(raw map[string]json.RawMessage) error {
+		delete(raw, "logs")
+		return nil
+	},
+}
+
+// A config written by a newer tjournal. Repairing it would throw away whatever this one doesn't know about.
+var ErrNewerConfig = errors.New("update tjournal to use it")
+
+// The version the file says it is, 0 for files from before versions
+func fileVersion(raw map[string]json.RawMessage) (int, error) {
+	version := 0
+	if v, ok := raw["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return 0, fmt.Errorf("version: %w", err)
+		}
+	}
+	if version > ConfigVersion {
+		return version, fmt.Errorf("config is version %d, this tjournal only knows up to %d: %w", version, ConfigVersion, ErrNewerConfig)
+	}
+	return version, nil
+}
+
+// Errors with ErrNewerConfig if the config is from a newer tjournal. Anything else is left to validate.
+func checkNewer(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+	if _, err := fileVersion(raw); errors.Is(err, ErrNewerConfig) {
+		return err
+	}
+	return nil
+}
+
+// Runs every migration the file still needs. Reports whether anything changed.
+func migrate(raw map[string]json.RawMessage) (bool, error) {
+	version, err := fileVersion(raw)
+	if err != nil {
+		return false, err
+	}
+	if version == ConfigVersion {
+		return false, nil
+	}
+
+	for ; version < ConfigVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return false, fmt.Errorf("migrating config from version %d: %w", version, err)
+		}
+	}
+	raw["version"] = json.RawMessage(fmt.Sprint(ConfigVersion))
+	return true, nil
+}
+
+// Decodes a config file, migrating it if it's from an older version
+func decodeConfig(data []byte) (*LocalConfig, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return &LocalConfig{}, err
+	}
+
+	if _, err := migrate(raw); err != nil {
+		return &LocalConfig{}, err
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return &LocalConfig{}, err
+	}
+
+	var localConfig LocalConfig
+	if err := json.Unmarshal(migrated, &localConfig); err != nil {
+		return &localConfig, err
+	}
+	return &localConfig, nil
+}
+
+// Saves the config in the current format if it's from an older version.
+// Reading migrates in memory anyway, this just saves doing it every run.
+func MigrateConfigFile() error {
+	data, err := os.ReadFile(ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var version int
+	json.Unmarshal(raw["version"], &version)
+	if version >= ConfigVersion {
+		return nil
+	}
+
+	return UpdateConfig(func(config *LocalConfig) error { return nil })
+}
+
+// One thing wrong with the config
+type FieldError struct {
+	Field   string
+	Problem string
+	// Unknown fields and the like, the config still works
+	Warning bool
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Problem
+	}
+	return e.Field + ": " + e.Problem
+}
+
+// Checks the config file field by field. An empty result means it's fine.
+// A config from a newer tjournal is an ErrNewerConfig error, not something to repair.
+func ValidateConfig() ([]FieldError, error) {
+	data, err := os.ReadFile(ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNewer(data); err != nil {
+		return nil, err
+	}
+	problems, _ := validate(data)
+	return problems, nil
+}
+
+// Problems with the config plus the raw fields that passed, what a repair keeps
+func validate(data []byte) ([]FieldError, map[string]json.RawMessage) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return []FieldError{{Problem: describeSyntaxError(data, err)}}, nil
+	}
+
+	var problems []FieldError
+	if _, err := fileVersion(raw); err != nil && !errors.Is(err, ErrNewerConfig) {
+		// A version that isn't a number says nothing about the format. Check the rest as the
+		// current one, so a repair keeps them.
+		problems = append(problems, FieldError{Field: "version", Problem: describeTypeError(reflect.TypeOf(0), errors.Unwrap(err))})
+		raw["version"] = json.RawMessage(fmt.Sprint(ConfigVersion))
+	}
+
+	if _, err := migrate(raw); err != nil {
+		return append(problems, FieldError{Field: "version", Problem: err.Error()}), nil
+	}
+
+	good := make(map[string]json.RawMessage)
+	fields := configFields()
+
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var config LocalConfig
+	for _, name := range names {
+		field, ok := fields[name]
+		if !ok {
+			problems = append(problems, FieldError{Field: name, Problem: "unknown field, it's ignored", Warning: true})
+			continue
+		}
+
+		// Decode each field on its own so every bad one gets reported, not just the first
+		value := reflect.New(field.Type)
+		if err := json.Unmarshal(raw[name], value.Interface()); err != nil {
+			problems = append(problems, FieldError{Field: name, Problem: describeTypeError(field.Type, err)})
+			continue
+		}
+		reflect.ValueOf(&config).Elem().FieldByIndex(field.Index).Set(value.Elem())
+
+		if problem := checkField(name, &config); problem != "" {
+			problems = append(problems, FieldError{Field: name, Problem: problem})
+			continue
+		}
+		good[name] = raw[name]
+	}
+
+	// Theme can name one from themes, so it's checked once both are in
+	if _, ok := good["theme"]; ok && config.Theme != "" {
+		if _, err := theme.Resolve(config.Theme, config.Themes); err != nil {
+			problems = append(problems, FieldError{Field: "theme", Problem: err.Error()})
+			delete(good, "theme")
+		}
+	}
+
+	return problems, good
+}
+
+// json name to struct field
+func configFields() map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	t := reflect.TypeOf(LocalConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" || name == "" {
+			continue
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// Checks that go beyond the type, on the field that was just decoded into config
+func checkField(name string, config *LocalConfig) string {
+	nonNegative := map[string]int{
+		"refresh_interval":    config.RefreshInterval,
+		"page_size":           config.PageSize,
+		"connect_timeout":     config.ConnectTimeout,
+		"request_timeout":     config.RequestTimeout,
+		"retry_attempts":      config.RetryAttempts,
+		"retry_base_delay_ms": config.RetryBaseDelayMs,
+		"retry_max_delay_ms":  config.RetryMaxDelayMs,
+		"wake_timeout":        config.WakeTimeout,
+	}
+	if value, ok := nonNegative[name]; ok {
+		if value < 0 {
+			return fmt.Sprintf("can't be negative, got %d", value)
+		}
+		return ""
+	}
+
+	switch name {
+	case "keys":
+		if _, err := bubble_init.LoadKeymap(config.Keys); err != nil {
+			return err.Error()
+		}
+	case "e2e_tags":
+		if config.E2ETags != "" && config.E2ETags != "plain" && config.E2ETags != "hash" {
+			return fmt.Sprintf("must be \"plain\" or \"hash\", got %q", config.E2ETags)
+		}
+	case "server":
+		if config.Server != "" {
+			u, err := url.Parse(config.Server)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Sprintf("must be a http(s) url, got %q", config.Server)
+			}
+		}
+	case "timezone":
+		if _, err := time.LoadLocation(config.Timezone); err != nil {
+			return fmt.Sprintf("unknown timezone %q", config.Timezone)
+		}
+	case "output":
+		if config.Output != "" && config.Output != "text" && config.Output != "json" {
+			return fmt.Sprintf("must be \"text\" or \"json\", got %q", config.Output)
+		}
+	case "log_file":
+		if config.LogFile != "" {
+			if _, err := os.Stat(filepath.Dir(config.LogFile)); err != nil {
+				return "folder doesn't exist: " + filepath.Dir(config.LogFile)
+			}
+		}
+	}
+	return ""
+}
+
+func describeTypeError(want reflect.Type, err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Sprintf("expected %s, got %s", typeName(want), typeErr.Value)
+	}
+	return err.Error()
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int:
+		return "a whole number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map:
+		return "an object"
+	}
+	return t.String()
+}
+
+// Points at the line and column of a json syntax error
+func describeSyntaxError(data []byte, err error) string {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return "not valid json: " + err.Error()
+	}
+
+	before := data[:min(int(syntaxErr.Offset), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("not valid json, line %d column %d: %s", line, col, syntaxErr.Error())
+}
+
+// Fixes the config by dropping the fields that are wrong, they fall back to their defaults.
+// A file that isn't json at all is moved aside to .broken and replaced with an empty config.
+// One from a newer tjournal is left alone.
+func RepairConfig() ([]FieldError, error) {
+	lock, err := fsutil.LockFile(ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	data, err := os.ReadFile(ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNewer(data); err != nil {
+		return nil, err
+	}
+
+	problems, good := validate(data)
+	if good == nil {
+		if err := os.WriteFile(ConfigPath+".broken", data, 0600); err != nil {
+			return nil, err
+		}
+		good = map[string]json.RawMessage{}
+	}
+	good["version"] = json.RawMessage(fmt.Sprint(ConfigVersion))
+
+	repaired, err := json.MarshalIndent(good, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	return problems, fsutil.WriteAtomic(ConfigPath, repaired, 0600)
+}
+
+// Only warnings, nothing that stops the config from loading
+func HasErrors(problems []FieldError) bool {
+	for _, p := range problems {
+		if !p.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+func PrintProblems(problems []FieldError) {
+	for _, problem := range problems {
+		if problem.Warning {
+			LogColourPrint("warning "+problem.Error(), "yellow")
+		} else {
+			LogColourPrint("error   "+problem.Error(), "red")
+		}
+	}
+}
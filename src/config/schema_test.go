@@ -0,0 +1,171 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apooravm/tjournal/src/secret"
+)
+
+type memStore struct {
+	token string
+}
+
+func (s *memStore) Load() (string, error) {
+	if s.token == "" {
+		return "", secret.ErrNoSecret
+	}
+	return s.token, nil
+}
+func (s *memStore) Save(token string) error { s.token = token; return nil }
+func (s *memStore) Delete() error           { s.token = ""; return nil }
+func (s *memStore) Exists() bool            { return s.token != "" }
+
+// Config and secret store in a temp dir, put back after the test
+func tempConfig(t *testing.T, contents string) *memStore {
+	t.Helper()
+	oldPath, oldSecrets := ConfigPath, Secrets
+	t.Cleanup(func() { ConfigPath, Secrets = oldPath, oldSecrets })
+
+	store := &memStore{}
+	ConfigPath = filepath.Join(t.TempDir(), "tjournalConfig.json")
+	Secrets = store
+	if err := os.WriteFile(ConfigPath, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestMigrate(t *testing.T) {
+	raw := map[string]json.RawMessage{"logs": json.RawMessage("null"), "username": json.RawMessage(`"a"`)}
+	changed, err := migrate(raw)
+	if err != nil || !changed {
+		t.Fatalf("migrate() = %v, %v", changed, err)
+	}
+	if _, ok := raw["logs"]; ok {
+		t.Error("logs still there after migrating")
+	}
+	if string(raw["version"]) != "2" {
+		t.Errorf("version = %s, want 2", raw["version"])
+	}
+
+	changed, err = migrate(raw)
+	if err != nil || changed {
+		t.Errorf("migrating a current config: %v, %v", changed, err)
+	}
+
+	if _, err := migrate(map[string]json.RawMessage{"version": json.RawMessage("99")}); err == nil {
+		t.Error("a config from a newer version should be refused")
+	}
+}
+
+// An old config has the token in plain text. Migrating moves it to the secret store
+// and the .bak copy of the old file mustn't keep it.
+func TestMigrateConfigFileMovesToken(t *testing.T) {
+	store := tempConfig(t, `{"token": "SECRETTOKEN", "username": "a", "email": "a@b.c", "logs": null}`)
+
+	if err := MigrateConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.token != "SECRETTOKEN" {
+		t.Errorf("secret store has %q, want the token", store.token)
+	}
+	for _, path := range []string{ConfigPath, ConfigPath + ".bak"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "SECRETTOKEN") {
+			t.Errorf("%s still has the token:\n%s", filepath.Base(path), data)
+		}
+	}
+
+	config, err := ReadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Version != ConfigVersion || config.Token != "" {
+		t.Errorf("read back version %d token %q", config.Version, config.Token)
+	}
+	if err := config.LoadToken(); err != nil || config.Token != "SECRETTOKEN" {
+		t.Errorf("LoadToken() = %v, token %q", err, config.Token)
+	}
+}
+
+// A damaged config falls back on .bak, which mustn't hand back the old token
+func TestDamagedConfigBackupHasNoToken(t *testing.T) {
+	tempConfig(t, `{"token": "SECRETTOKEN", "username": "a", "version": 2}`)
+
+	config, err := ReadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(ConfigPath, []byte("{broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := ReadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup.Token != "" || backup.Username != "a" {
+		t.Errorf("backup gave token %q username %q", backup.Token, backup.Username)
+	}
+}
+
+// A config from a newer tjournal isn't offered a repair, which would drop everything this one doesn't know
+func TestNewerConfigIsNotRepaired(t *testing.T) {
+	contents := `{"version": 99, "username": "a", "email": "a@b.c", "theme": "dracula"}`
+	tempConfig(t, contents)
+
+	if _, err := ValidateConfig(); !errors.Is(err, ErrNewerConfig) {
+		t.Errorf("ValidateConfig() error = %v, want ErrNewerConfig", err)
+	}
+	if _, err := RepairConfig(); !errors.Is(err, ErrNewerConfig) {
+		t.Errorf("RepairConfig() error = %v, want ErrNewerConfig", err)
+	}
+
+	data, err := os.ReadFile(ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != contents {
+		t.Errorf("config changed to %s", data)
+	}
+}
+
+// Only the version is wrong, so a repair keeps everything else
+func TestRepairMalformedVersion(t *testing.T) {
+	tempConfig(t, `{"version": "two", "username": "a", "email": "a@b.c", "page_size": -1}`)
+
+	problems, err := ValidateConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	for _, p := range problems {
+		fields = append(fields, p.Field)
+	}
+	if strings.Join(fields, ",") != "version,page_size" {
+		t.Errorf("problems with %v, want version and page_size", fields)
+	}
+
+	if _, err := RepairConfig(); err != nil {
+		t.Fatal(err)
+	}
+	config, err := ReadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Version != ConfigVersion || config.Username != "a" || config.Email != "a@b.c" || config.PageSize != 0 {
+		t.Errorf("repaired to %+v", config)
+	}
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apooravm/tjournal/src/fsutil"
+	"github.com/apooravm/tjournal/src/theme"
+)
+
+// Managed by login, encryption and the app itself, config set leaves them alone
+var readOnly = map[string]bool{
+	"version":   true,
+	"token":     true,
+	"username":  true,
+	"email":     true,
+	"e2e_check": true,
+}
+
+// Settings config get and set know about, sorted
+func SettingNames() []string {
+	var names []string
+	for name := range configFields() {
+		if !readOnly[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func settingField(name string) (reflect.StructField, error) {
+	field, ok := configFields()[name]
+	if !ok || name == "token" {
+		return field, fmt.Errorf("unknown setting %q, one of: %s", name, strings.Join(SettingNames(), ", "))
+	}
+	return field, nil
+}
+
+// The setting's value, strings as they are and everything else as json. Unset is empty.
+func GetSetting(name string) (string, error) {
+	field, err := settingField(name)
+	if err != nil {
+		return "", err
+	}
+
+	config, err := ReadConfig()
+	if err != nil {
+		return "", err
+	}
+
+	value := reflect.ValueOf(config).Elem().FieldByIndex(field.Index)
+	if value.IsZero() {
+		return "", nil
+	}
+	if value.Kind() == reflect.String {
+		return value.String(), nil
+	}
+
+	byteArr, err := json.Marshal(value.Interface())
+	if err != nil {
+		return "", err
+	}
+	return string(byteArr), nil
+}
+
+// Sets a setting from command line args. Lists take several args or one comma separated,
+// objects like keys and themes take a json literal. Nothing is saved if the value is invalid.
+func SetSetting(name string, args []string) error {
+	field, err := settingField(name)
+	if err != nil {
+		return err
+	}
+	if readOnly[name] {
+		return fmt.Errorf("%s can't be set by hand", name)
+	}
+
+	value := reflect.New(field.Type)
+	if err := parseSetting(field.Type, args, value.Interface()); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return UpdateConfig(func(config *LocalConfig) error {
+		reflect.ValueOf(config).Elem().FieldByIndex(field.Index).Set(value.Elem())
+		return checkSetting(name, config)
+	})
+}
+
+// Back to the default
+func UnsetSetting(name string) error {
+	field, err := settingField(name)
+	if err != nil {
+		return err
+	}
+	if readOnly[name] {
+		return fmt.Errorf("%s can't be set by hand", name)
+	}
+
+	return UpdateConfig(func(config *LocalConfig) error {
+		target := reflect.ValueOf(config).Elem().FieldByIndex(field.Index)
+		target.Set(reflect.Zero(field.Type))
+		return nil
+	})
+}
+
+func parseSetting(t reflect.Type, args []string, into any) error {
+	joined := strings.Join(args, " ")
+	if len(args) == 0 {
+		return fmt.Errorf("needs a value")
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		reflect.ValueOf(into).Elem().SetString(joined)
+	case reflect.Int:
+		n, err := strconv.Atoi(joined)
+		if err != nil {
+			return fmt.Errorf("expected a whole number, got %q", joined)
+		}
+		reflect.ValueOf(into).Elem().SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(joined)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", joined)
+		}
+		reflect.ValueOf(into).Elem().SetBool(b)
+	case reflect.Slice:
+		if strings.HasPrefix(joined, "[") {
+			return json.Unmarshal([]byte(joined), into)
+		}
+		var items []string
+		for _, arg := range args {
+			for _, item := range strings.Split(arg, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+		reflect.ValueOf(into).Elem().Set(reflect.ValueOf(items))
+	default:
+		if err := json.Unmarshal([]byte(joined), into); err != nil {
+			return fmt.Errorf("expected a json object: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkField plus the checks that need more than one field
+func checkSetting(name string, config *LocalConfig) error {
+	if problem := checkField(name, config); problem != "" {
+		return fmt.Errorf("%s: %s", name, problem)
+	}
+	if (name == "theme" || name == "themes") && config.Theme != "" {
+		if _, err := theme.Resolve(config.Theme, config.Themes); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Opens the config in the editor. The edit is only saved once it validates,
+// until then it can be edited again or thrown away.
+func EditConfig() error {
+	config, err := ReadConfig()
+	if err != nil {
+		return err
+	}
+	editor := editorCommand(config.Editor)
+
+	original, err := os.ReadFile(ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	// Edit a copy so a half saved file never goes live
+	tmp, err := os.CreateTemp(filepath.Dir(ConfigPath), ".tjournalConfig.edit*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(original); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	for {
+		if err := runEditor(editor, tmp.Name()); err != nil {
+			return err
+		}
+
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, original) {
+			LogColourPrint("No changes", "yellow")
+			return nil
+		}
+
+		problems, _ := validate(edited)
+		PrintProblems(problems)
+		if !HasErrors(problems) {
+			return saveEdited(edited)
+		}
+
+		if !Confirm("The config has errors. Edit again? No throws the changes away") {
+			return fmt.Errorf("changes discarded")
+		}
+	}
+}
+
+func saveEdited(data []byte) error {
+	lock, err := fsutil.LockFile(ConfigPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return fsutil.WriteAtomic(ConfigPath, data, 0600)
+}
+
+// Opens any file in the user's editor and waits for it to close
+func EditFile(config *LocalConfig, path string) error {
+	return runEditor(editorCommand(config.Editor), path)
+}
+
+// Config first, then $VISUAL and $EDITOR, then something that's usually there
+func editorCommand(configured string) string {
+	for _, editor := range []string{configured, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if strings.TrimSpace(editor) != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// The editor can come with args, like "code --wait"
+func runEditor(editor string, path string) error {
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running editor %q: %w", editor, err)
+	}
+	return nil
+}
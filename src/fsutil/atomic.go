@@ -0,0 +1,70 @@
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Writes data so that path always holds either the old or the new content, never half of one.
+// The data goes to a temp file in the same directory which is then renamed over path.
+// The previous content is kept as path.bak.
+func WriteAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// No-op once the rename went through
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	// Make sure the bytes are on disk before the rename makes them live
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := backup(path, perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// Copies the current file to path.bak, nothing to do if there's no file yet
+func backup(path string, perm os.FileMode) error {
+	old, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path+".bak", old, perm)
+}
+
+// Persists the rename itself. Best effort, not every platform can open a directory for this.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
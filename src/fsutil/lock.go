@@ -0,0 +1,34 @@
+package fsutil
+
+import (
+	"os"
+)
+
+// Advisory lock shared by every tjournal process, held on path.lock so the file itself
+// can still be renamed over. Unlock releases it.
+type Lock struct {
+	file *os.File
+}
+
+// Blocks until the lock for path is free
+func LockFile(path string) (*Lock, error) {
+	file, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lock(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Lock{file: file}, nil
+}
+
+func (l *Lock) Unlock() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package fsutil
+
+import "os"
+
+// No file locking here, writes are still atomic
+func lock(file *os.File) error {
+	return nil
+}
+
+func unlock(file *os.File) error {
+	return nil
+}
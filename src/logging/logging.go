@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+type Options struct {
+	// Info and up
+	Verbose bool
+	// Everything, requests included
+	Debug bool
+	// Appended to when set, otherwise logs go to stderr
+	File string
+	// The TUI owns the terminal, without a file the logs are dropped rather than drawn over it
+	Quiet bool
+	// Rotating log of what the app did, kept whatever the other options say. Empty turns it off.
+	ActivityPath string
+}
+
+func (o Options) level() slog.Level {
+	switch {
+	case o.Debug:
+		return slog.LevelDebug
+	case o.Verbose:
+		return slog.LevelInfo
+	}
+	return slog.LevelWarn
+}
+
+// The activity log is capped at this size times backups+1, and nothing older than activityMaxAge
+const (
+	activityMaxSize    = 1 << 20
+	activityMaxBackups = 3
+	activityMaxAge     = 30 * 24 * time.Hour
+)
+
+// Builds the app's logger. The returned close func flushes the log files.
+func New(opts Options) (*slog.Logger, func() error, error) {
+	handlerOpts := &slog.HandlerOptions{Level: opts.level()}
+
+	var handlers []slog.Handler
+	var closers []func() error
+
+	if opts.ActivityPath != "" {
+		activity := NewRotatingFile(opts.ActivityPath, activityMaxSize, activityMaxBackups, activityMaxAge)
+		// Always info and up, whatever the flags, so there's a history to attach to bug reports
+		activityLevel := min(opts.level(), slog.LevelInfo)
+		handlers = append(handlers, slog.NewTextHandler(activity, &slog.HandlerOptions{Level: activityLevel}))
+		closers = append(closers, activity.Close)
+	}
+
+	if opts.File != "" {
+		file, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, nil, err
+		}
+		handlers = append(handlers, slog.NewJSONHandler(file, handlerOpts))
+		closers = append(closers, file.Close)
+	} else if !opts.Quiet {
+		handlers = append(handlers, slog.NewTextHandler(os.Stderr, handlerOpts))
+	}
+
+	closeAll := func() error {
+		var errs []error
+		for _, c := range closers {
+			errs = append(errs, c())
+		}
+		return errors.Join(errs...)
+	}
+
+	return slog.New(fanout(handlers)), closeAll, nil
+}
+
+// Sends every record to each handler that wants it
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
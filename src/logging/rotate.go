@@ -0,0 +1,279 @@
+package logging
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apooravm/tjournal/src/fsutil"
+)
+
+// A log file that starts over once it gets too big. Old files are kept as path.1, path.2...
+// up to MaxBackups. Entries older than MaxAge go when the file is opened and when it rotates.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+	MaxAge     time.Duration
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewRotatingFile(path string, maxSize int64, maxBackups int, maxAge time.Duration) *RotatingFile {
+	return &RotatingFile{Path: path, MaxSize: maxSize, MaxBackups: maxBackups, MaxAge: maxAge}
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.expire(); err != nil {
+			return 0, err
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.size+int64(len(p)) > r.MaxSize {
+		if err := r.rotate(int64(len(p))); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Shifts the files along under a lock, other tjournal processes write to the same log
+func (r *RotatingFile) rotate(incoming int64) error {
+	lock, err := fsutil.LockFile(r.Path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	r.file.Close()
+	r.file = nil
+
+	// Someone else may have rotated while we waited for the lock
+	if info, err := os.Stat(r.Path); err == nil && info.Size()+incoming <= r.MaxSize {
+		return r.open()
+	}
+
+	if err := r.shift(); err != nil {
+		return err
+	}
+	r.dropOld()
+	return r.open()
+}
+
+// Moves the live file to path.1, and the backups along one. Caller holds the lock.
+func (r *RotatingFile) shift() error {
+	for i := r.MaxBackups; i > 0; i-- {
+		from := r.Path
+		if i > 1 {
+			from = fmt.Sprintf("%s.%d", r.Path, i-1)
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", r.Path, i)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if r.MaxBackups <= 0 {
+		os.Remove(r.Path)
+	}
+	return nil
+}
+
+// MaxAge on open. A quiet install never fills MaxSize, so waiting for a rotation would keep
+// old entries forever. The live file is rotated out once its first entry is too old.
+func (r *RotatingFile) expire() error {
+	if r.MaxAge <= 0 {
+		return nil
+	}
+
+	lock, err := fsutil.LockFile(r.Path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if first, ok := firstEntry(r.Path); ok && time.Since(first) > r.MaxAge {
+		if err := r.shift(); err != nil {
+			return err
+		}
+	}
+	r.dropOld()
+	return nil
+}
+
+// Removes backups past MaxAge, and the entries past it from the ones that are partly older.
+// Caller holds the lock.
+func (r *RotatingFile) dropOld() {
+	if r.MaxAge <= 0 {
+		return
+	}
+
+	cutoff := time.Now().Add(-r.MaxAge)
+	for _, path := range Backups(r.Path) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(cutoff) {
+			os.Remove(path)
+			continue
+		}
+		if first, ok := firstEntry(path); ok && first.Before(cutoff) {
+			trimBefore(path, cutoff)
+		}
+	}
+}
+
+// When the entry was logged, from slog's text format. ok is false for lines without a time.
+func entryTime(line string) (time.Time, bool) {
+	value, ok := strings.CutPrefix(line, "time=")
+	if !ok {
+		return time.Time{}, false
+	}
+	value, _, _ = strings.Cut(value, " ")
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t, err == nil
+}
+
+// Time of the first entry in the file
+func firstEntry(path string) (time.Time, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+
+	line, _ := bufio.NewReader(file).ReadString('\n')
+	return entryTime(strings.TrimRight(line, "\n"))
+}
+
+// Drops the lines logged before cutoff. Only for backups, nothing has them open to write to.
+func trimBefore(path string, cutoff time.Time) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	byteArr, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.SplitAfter(string(byteArr), "\n")
+	keep := len(lines)
+	for i, line := range lines {
+		if t, ok := entryTime(line); ok && !t.Before(cutoff) {
+			keep = i
+			break
+		}
+	}
+
+	if keep == len(lines) {
+		return os.Remove(path)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines[keep:], "")), 0600); err != nil {
+		return err
+	}
+	// Keep the age, it's when the last entry was written
+	os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	return os.Rename(tmp, path)
+}
+
+// The rotated files for path, oldest first
+func Backups(path string) []string {
+	matches, _ := filepath.Glob(path + ".*")
+
+	var backups []string
+	for _, match := range matches {
+		suffix := strings.TrimPrefix(match, path+".")
+		var n int
+		if _, err := fmt.Sscanf(suffix, "%d", &n); err == nil && fmt.Sprint(n) == suffix {
+			backups = append(backups, match)
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backupNumber(path, backups[i]) > backupNumber(path, backups[j])
+	})
+	return backups
+}
+
+func backupNumber(path string, backup string) int {
+	var n int
+	fmt.Sscanf(strings.TrimPrefix(backup, path+"."), "%d", &n)
+	return n
+}
+
+// The last n lines of the log, n <= 0 for all of them. withBackups reads the rotated files too.
+func Tail(path string, n int, withBackups bool) ([]string, error) {
+	files := []string{path}
+	if withBackups {
+		files = append(Backups(path), path)
+	}
+
+	var lines []string
+	for _, file := range files {
+		byteArr, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		text := strings.TrimRight(string(byteArr), "\n")
+		if text != "" {
+			lines = append(lines, strings.Split(text, "\n")...)
+		}
+	}
+
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
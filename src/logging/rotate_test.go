@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A line like slog's text handler writes
+func entry(at time.Time, msg string) string {
+	return fmt.Sprintf("time=%s level=INFO msg=%s\n", at.Format(time.RFC3339Nano), msg)
+}
+
+func TestRotateOnSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity.log")
+	r := NewRotatingFile(path, 100, 2, 0)
+	defer r.Close()
+
+	line := entry(time.Now(), "hello")
+	for i := 0; i < 20; i++ {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups := Backups(path)
+	if len(backups) != 2 {
+		t.Errorf("backups %v, want 2", backups)
+	}
+	for _, file := range append(backups, path) {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 100 {
+			t.Errorf("%s is %d bytes, over the cap", filepath.Base(file), info.Size())
+		}
+	}
+}
+
+// Nothing gets big enough to rotate, old entries still go once the file is opened
+func TestExpireOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity.log")
+	now := time.Now()
+	old, recent := now.Add(-40*24*time.Hour), now.Add(-time.Hour)
+
+	write := func(name string, contents string, modified time.Time) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Live file started long ago, last written an hour ago
+	write(path, entry(old, "live-old")+entry(recent, "live-new"), recent)
+	// Every entry too old
+	write(path+".1", entry(old, "gone"), old)
+
+	r := NewRotatingFile(path, 1<<20, 3, 30*24*time.Hour)
+	if _, err := r.Write([]byte(entry(now, "now"))); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	lines, err := Tail(path, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := strings.Join(lines, "\n")
+	for _, msg := range []string{"live-old", "gone"} {
+		if strings.Contains(all, "msg="+msg) {
+			t.Errorf("%s should have expired:\n%s", msg, all)
+		}
+	}
+	for _, msg := range []string{"live-new", "now"} {
+		if !strings.Contains(all, "msg="+msg) {
+			t.Errorf("%s is missing:\n%s", msg, all)
+		}
+	}
+
+	// The live file was rotated out, the old backup removed
+	if backups := Backups(path); len(backups) != 1 {
+		t.Errorf("backups %v, want just the rotated live file", backups)
+	}
+}
@@ -1,53 +1,246 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	api "github.com/apooravm/tjournal/src/api"
+	"github.com/apooravm/tjournal/src/bubble_init"
+	"github.com/apooravm/tjournal/src/cache"
 	configMng "github.com/apooravm/tjournal/src/config"
+	"github.com/apooravm/tjournal/src/logging"
+	"github.com/apooravm/tjournal/src/search"
+	"github.com/apooravm/tjournal/src/secret"
+	"github.com/apooravm/tjournal/src/stats"
+	"github.com/apooravm/tjournal/src/templates"
+	"github.com/apooravm/tjournal/src/theme"
 	ui "github.com/apooravm/tjournal/src/ui"
+	"golang.org/x/term"
 )
 
 var (
-	configName = "tjournalConfig.json"
-	base       = "https://multi-serve.onrender.com"
-	PingRoute  = "/api/ping"
-	JournRoute = "/api/journal/"
-	LoginRoute = "/api/user/login"
-	// App states: "quick_save", "quick_view", "tui_view", "tui_save"
+	configName   = "tjournalConfig.json"
+	cacheName    = "tjournalCache.json"
+	tokenName    = "tjournalToken.enc"
+	activityName = "tjournalActivity.log"
+	base         = "https://multi-serve.onrender.com"
+	PingRoute    = "/api/ping"
+	JournRoute   = "/api/journal/"
+	LoginRoute   = "/api/user/login"
+	LogoutRoute  = "/api/user/logout"
+	// App states: "quick_save", "quick_view", "tui_view", "tui_save", "stats", "login", "logout", "daily", "append", "search"
 	AppState      = ""
 	NewLogMessage = ""
+	// `new --template NAME`, and --no-edit to save it without opening the editor
+	NewTemplate = ""
+	NoEdit      = false
+	// `append <ID|today|last>`, the text goes in NewLogMessage
+	AppendTarget = ""
+	// `search QUERY`, -n caps the results and --refresh fetches everything first
+	SearchQuery   = ""
+	SearchLimit   = 20
+	SearchRefresh = false
+	LoginEmail    = ""
+	PasswordStdin = false
 	// Some cli args need the main func to return immediately. Toggle this flag for that.
 	return_flag = false
+	// --json, or "output": "json" in the config
+	OutputJSON = false
+	// Set by --verbose, --debug and --log-file, which work with any command
+	LogOpts = logging.Options{}
+	appLog  = slog.Default()
+	// Rotating log of what tjournal did, see `tjournal debug logs`
+	activityPath = ""
 )
 
+// Pulls the logging flags out of the args, wherever they are, and returns the rest
+func parseLogFlags(args []string) []string {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--verbose", "-verbose", "-v":
+			LogOpts.Verbose = true
+		case "--debug", "-debug":
+			LogOpts.Debug = true
+		case "--log-file", "-log-file":
+			if i+1 < len(args) {
+				LogOpts.File = args[i+1]
+				i++
+			}
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest
+}
+
 func handleCLIArg(cliArg []string) {
-	switch cliArg[0] {
-	case "-help":
+	// Commands work with or without the leading dash, `tjournal stats` and `tjournal -stats`
+	switch strings.TrimLeft(cliArg[0], "-") {
+	case "help":
 		fmt.Println(`Usage: 'tjournal.exe [ARG]' if arg needed
-\n
-\nAvailable Args
-\nnew    - New Log. Usage: 'tjournal.exe -new <YOUR_LOG>. Note: The tags and title are defaulted to 'Quick Note' and 'quick'.
-\ndelete - Delete user config.json
-\nhelp   - Display help`)
+
+Available Args
+new    - New Log. Usage: 'tjournal.exe new <YOUR_LOG>'. Note: The title is 'Quick Log' and the tags are 'quick', or default_tags from the config.
+         'tjournal.exe new --template <NAME> [TEXT]' fills a template and opens it in the editor, --no-edit skips that.
+         Templates are .txt files in the templates folder next to the config, standup, retro and incident are built in.
+daily  - Answer the daily questions, saved as one entry tagged 'daily'. Set them with 'config set daily_questions'.
+append - Add a timestamped line to an entry. Usage: 'tjournal.exe append <ID|today|last> <TEXT>'. today is today's newest entry, last the newest of all.
+search - Search titles, bodies and tags. 'tjournal.exe search' for the query syntax
+recent - Print your logs. Add --json for JSON output
+stats  - Journaling statistics. Add --json for JSON output
+login  - Log in. Usage: 'tjournal.exe login [EMAIL] [--password-stdin]'. Without --password-stdin the password is asked for, hidden.
+logout - Revoke the token and remove it from the config, along with the offline cache. Settings are kept.
+config - Read and change settings. 'tjournal.exe config' for more
+debug  - 'tjournal.exe debug logs [--all] [-n LINES]' shows what tjournal did, for bug reports
+delete - Delete user config.json and the offline cache
+help   - Display help
+
+Flags, with any arg
+--verbose         - Log what the app is doing to stderr
+--debug           - Log every request too
+--log-file <PATH> - Write the logs to a file instead
+
+The login token is stored encrypted under a passphrase. Set TJOURNAL_PASSPHRASE to skip the prompt.
+With "e2e": true in the config, logs are encrypted before they're sent. Set TJOURNAL_E2E_PASSPHRASE to skip that prompt.`)
 		return_flag = true
 
-	case "-new":
-		NewLogMessage = strings.Join(cliArg[1:], " ")
+	case "new":
 		AppState = "quick_save"
+		var words []string
+		for i := 1; i < len(cliArg); i++ {
+			switch arg := cliArg[i]; {
+			case arg == "--template" || arg == "-template" || arg == "-t":
+				if i+1 >= len(cliArg) {
+					fmt.Println("Templates: " + strings.Join(templates.List(), ", "))
+					return_flag = true
+					return
+				}
+				NewTemplate = cliArg[i+1]
+				i++
+			case strings.HasPrefix(arg, "--template="):
+				NewTemplate = strings.TrimPrefix(arg, "--template=")
+			case arg == "--no-edit" || arg == "-no-edit":
+				NoEdit = true
+			default:
+				words = append(words, arg)
+			}
+		}
+		NewLogMessage = strings.Join(words, " ")
+
+	case "daily":
+		AppState = "daily"
+
+	case "append":
+		AppState = "append"
+		if len(cliArg) < 3 {
+			fmt.Println("Usage: 'tjournal.exe append <ID|today|last> <TEXT>'")
+			return_flag = true
+			return
+		}
+		AppendTarget = cliArg[1]
+		NewLogMessage = strings.Join(cliArg[2:], " ")
 
-	case "-recent":
+	case "search":
+		AppState = "search"
+		var words []string
+		for i := 1; i < len(cliArg); i++ {
+			switch arg := cliArg[i]; arg {
+			case "--json", "-json":
+				OutputJSON = true
+			case "--refresh", "-refresh":
+				SearchRefresh = true
+			case "-n", "--limit", "-limit":
+				n := -1
+				if i+1 < len(cliArg) {
+					n, _ = strconv.Atoi(cliArg[i+1])
+				}
+				if n < 0 {
+					fmt.Println(arg + " takes a number")
+					return_flag = true
+					return
+				}
+				SearchLimit = n
+				i++
+			default:
+				words = append(words, arg)
+			}
+		}
+		if len(words) == 0 {
+			fmt.Println(searchUsage)
+			return_flag = true
+			return
+		}
+		SearchQuery = strings.Join(words, " ")
+
+	case "recent":
 		AppState = "quick_view"
+		for _, arg := range cliArg[1:] {
+			if arg == "--json" || arg == "-json" {
+				OutputJSON = true
+			}
+		}
 
-	case "-delete":
+	case "stats":
+		AppState = "stats"
+		for _, arg := range cliArg[1:] {
+			if arg == "--json" || arg == "-json" {
+				OutputJSON = true
+			}
+		}
+
+	case "login":
+		AppState = "login"
+		for _, arg := range cliArg[1:] {
+			if arg == "--password-stdin" || arg == "-password-stdin" {
+				PasswordStdin = true
+			} else {
+				LoginEmail = arg
+			}
+		}
+
+	case "logout":
+		AppState = "logout"
+
+	case "config":
+		return_flag = true
+		if len(cliArg) < 2 {
+			fmt.Println(configUsage)
+			return
+		}
+		if !configCommand(cliArg[1], cliArg[2:]) {
+			os.Exit(1)
+		}
+
+	case "debug":
+		return_flag = true
+		if len(cliArg) < 2 || cliArg[1] != "logs" {
+			fmt.Println(debugUsage)
+			return
+		}
+		showActivity(cliArg[2:])
+
+	case "delete":
 		if configMng.ConfigFileExists() {
+			if !discardPending() {
+				return
+			}
 			if err := configMng.DeleteConfigFile(); err != nil {
 				configMng.LogColourPrint("\nError deleting config\n", "red")
 
 			} else {
+				clearCache()
 				configMng.LogColourPrint("\nConfig deleted successfully!\n", "green")
 
 			}
@@ -63,6 +256,761 @@ func handleCLIArg(cliArg []string) {
 
 }
 
+// How long to keep pinging a sleeping server
+const defaultWakeTimeout = 90 * time.Second
+
+// How long a ctrl+c waits for the run to wind down before exiting anyway
+const interruptGrace = time.Second
+
+// Ctrl+C cancels whatever request is in flight so it can wind down, then ends the run.
+// A prompt waiting on stdin never sees the cancel, so it's cut short after interruptGrace.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
+	// A password prompt has echo off, put it back before leaving
+	var termState *term.State
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		termState, _ = term.GetState(fd)
+	}
+
+	go func() {
+		select {
+		case <-interrupts:
+		case <-ctx.Done():
+			return
+		}
+		cancel()
+		// A second ctrl+c goes straight through
+		signal.Stop(interrupts)
+
+		time.Sleep(interruptGrace)
+		if termState != nil {
+			term.Restore(int(os.Stdin.Fd()), termState)
+		}
+		fmt.Fprintln(os.Stderr)
+		os.Exit(130)
+	}()
+
+	return ctx, func() {
+		signal.Stop(interrupts)
+		cancel()
+	}
+}
+
+// Pings the server, printing a progress line once it's clearly asleep and waking up
+func waitForServer(ctx context.Context, wakeTimeout time.Duration) bool {
+	start := time.Now()
+	var attempt atomic.Int32
+	attempt.Store(1)
+	// Set once the server has answered with a 5xx, it's there but still starting
+	var waking atomic.Bool
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		defer close(stopped)
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				elapsed := time.Since(start).Round(time.Second)
+				if elapsed < 2*time.Second {
+					continue
+				}
+				// A first ping that hangs is a sleeping instance too, Render holds the connection while it boots
+				msg := "Connecting…"
+				if waking.Load() || attempt.Load() == 1 {
+					msg = "Waking server…"
+				}
+				fmt.Fprintf(os.Stderr, "\r%s", configMng.LogColourSprintf(fmt.Sprintf("%s %s (attempt %d)", msg, elapsed, attempt.Load()), "yellow"))
+			}
+		}
+	}()
+
+	status := api.WaitForServer(ctx, base+PingRoute, wakeTimeout, func(n int, isWaking bool) {
+		attempt.Store(int32(n))
+		waking.Store(isWaking)
+	})
+
+	close(done)
+	<-stopped
+	if time.Since(start) >= 2*time.Second {
+		fmt.Fprintln(os.Stderr)
+	}
+	return status
+}
+
+// Logs written offline are lost with the cache, check before logging out
+func discardPending() bool {
+	localCache, err := cache.Load()
+	if err != nil || len(localCache.Pending) == 0 {
+		return true
+	}
+	configMng.LogColourPrint(fmt.Sprintf("%d logs written offline haven't been sent yet. Run tjournal while online to send them.", len(localCache.Pending)), "yellow")
+	return configMng.Confirm("Log out and lose them?")
+}
+
+// The cache is the logged out user's journal, it mustn't be left for the next one
+func clearCache() {
+	if err := cache.Delete(); err != nil {
+		configMng.LogColourPrint("Error removing the local cache: "+err.Error(), "red")
+		return
+	}
+}
+
+// All logs from the server, refreshing the cache on the way. Offline they come from the cache.
+func readAllLogs(ctx context.Context, online bool, journal *api.JournalDB, localCache *cache.Cache) (*[]api.ReadJournalLogRes, error) {
+	if !online {
+		if localCache.FetchedAt.IsZero() {
+			return nil, fmt.Errorf("no cached logs yet, connect once to fetch them")
+		}
+
+		if localCache.Complete() {
+			configMng.LogColourPrint("Showing cached logs, last fully synced "+localCache.SyncedAt.Format("02 Jan 2006 15:04"), "yellow")
+		} else {
+			configMng.LogColourPrint(fmt.Sprintf("Showing the %d cached logs, some may be missing. The cache was last updated %s",
+				len(localCache.Logs), localCache.FetchedAt.Format("02 Jan 2006 15:04")), "yellow")
+		}
+		return &localCache.Logs, nil
+	}
+
+	logs, err := journal.ReadJournalLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	localCache.Replace(*logs)
+	if err := cache.StoreAll(*logs); err != nil {
+		configMng.LogColourPrint("Error updating the local cache: "+err.Error(), "yellow")
+	}
+	return logs, nil
+}
+
+const configUsage = `Usage: 'tjournal.exe config <COMMAND>'
+
+get [NAME]          - Print a setting, or all of them without a name
+set <NAME> <VALUE>  - Change a setting. Lists take several values or one comma separated, keys and themes take json
+unset <NAME>        - Back to the default
+edit                - Open the config in the editor, it's checked before it's saved
+validate            - Check the config and say which field is wrong`
+
+const searchUsage = `Usage: 'tjournal.exe search <QUERY> [--json] [-n LIMIT] [--refresh]'
+
+standup notes       both words, anywhere
+"standup notes"     the exact phrase
+stand*              any word starting with stand
+standup OR retro    either
+NOT retro, -retro   without
+(a OR b) c          grouping
+
+Best matches first, title hits count more than body hits. Quote the query so the shell leaves it alone.
+The offline cache is searched. The whole journal is fetched again first when it hasn't been in the last hour,
+when logs were written since, or with --refresh.`
+
+// How old the cache can get before search fetches everything again
+const searchCacheAge = time.Hour
+
+// Whether search can skip the server. The user isn't known yet, so this goes by the config's username.
+func searchFromCache() bool {
+	if SearchRefresh {
+		return false
+	}
+	localCache, err := cache.Load()
+	return err == nil && localCache.Fresh(searchCacheAge)
+}
+
+// The `search` command. Searches the cache, online means it was too old and gets fetched again.
+func searchLogs(ctx context.Context, online bool, journal *api.JournalDB, localCache *cache.Cache, query string, limit int) {
+	q, err := search.Parse(query)
+	if err != nil {
+		configMng.LogColourPrint("Bad search: "+err.Error(), "red")
+		return
+	}
+
+	logs, err := readAllLogs(ctx, online, journal, localCache)
+	if err != nil && online && !localCache.FetchedAt.IsZero() {
+		configMng.LogColourPrint("Couldn't fetch the logs, searching the cache instead: "+err.Error(), "yellow")
+		logs, err = readAllLogs(ctx, false, journal, localCache)
+	}
+	if err != nil {
+		printAPIError("Error reading logs", err)
+		return
+	}
+
+	results := search.Build(*logs).Run(q)
+	appLog.Debug("search", "query", query, "results", len(results))
+	total := len(results)
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	if OutputJSON {
+		out, err := json.MarshalIndent(results, "", "    ")
+		if err != nil {
+			configMng.LogColourPrint(err.Error(), "red")
+			return
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	if total == 0 {
+		fmt.Println("No matches")
+		return
+	}
+	if total > len(results) {
+		fmt.Printf("%d matches, showing the best %d\n\n", total, len(results))
+	} else {
+		fmt.Printf("%d matches\n\n", total)
+	}
+
+	for _, result := range results {
+		log := result.Log
+		header := fmt.Sprintf("#%d  %s", log.Log_Id, log.Title)
+		if created, ok := log.CreatedTime(); ok {
+			header += "  " + created.Format("02 Jan 2006")
+		}
+		fmt.Println(configMng.LogColourSprintf(header, "cyan"))
+		fmt.Println("    " + search.Snippet(log.Log, q, 100))
+		if len(log.Tags) > 0 {
+			fmt.Println("    " + strings.Join(log.Tags, ", "))
+		}
+		fmt.Println("")
+	}
+}
+
+// The config subcommands, false when it failed
+func configCommand(command string, args []string) bool {
+	if command != "validate" && !configMng.ConfigFileExists() {
+		configMng.LogColourPrint("No config yet, run tjournal to log in", "yellow")
+		return false
+	}
+
+	var err error
+	switch command {
+	case "get":
+		if len(args) > 0 {
+			var value string
+			if value, err = configMng.GetSetting(args[0]); err == nil {
+				fmt.Println(value)
+			}
+			break
+		}
+		for _, name := range configMng.SettingNames() {
+			value, _ := configMng.GetSetting(name)
+			fmt.Printf("%-20s %s\n", name, value)
+		}
+
+	case "set":
+		if len(args) < 2 {
+			err = errors.New("Usage: 'tjournal.exe config set <NAME> <VALUE>'")
+			break
+		}
+		err = configMng.SetSetting(args[0], args[1:])
+
+	case "unset":
+		if len(args) < 1 {
+			err = errors.New("Usage: 'tjournal.exe config unset <NAME>'")
+			break
+		}
+		err = configMng.UnsetSetting(args[0])
+
+	case "edit":
+		err = configMng.EditConfig()
+
+	case "validate":
+		return validateConfig()
+
+	default:
+		fmt.Println(configUsage)
+		return false
+	}
+
+	if err != nil {
+		configMng.LogColourPrint(err.Error(), "red")
+		return false
+	}
+	return true
+}
+
+// Prints what's wrong with the config, false if it can't be used as is
+func validateConfig() bool {
+	if !configMng.ConfigFileExists() {
+		configMng.LogColourPrint("No config yet, run tjournal to log in", "yellow")
+		return true
+	}
+
+	problems, err := configMng.ValidateConfig()
+	if err != nil {
+		configMng.LogColourPrint("Error reading config: "+err.Error(), "red")
+		return false
+	}
+
+	configMng.PrintProblems(problems)
+	if len(problems) == 0 {
+		configMng.LogColourPrint("Config OK", "green")
+	}
+	return !configMng.HasErrors(problems)
+}
+
+// Runs before anything uses the config. A broken one gets offered a repair instead of failing later.
+func checkConfig() bool {
+	problems, err := configMng.ValidateConfig()
+	if errors.Is(err, configMng.ErrNewerConfig) {
+		configMng.LogColourPrint("Can't use the config: "+err.Error(), "red")
+		return false
+	}
+	if err != nil {
+		configMng.LogColourPrint("Error reading config: "+err.Error(), "red")
+		return false
+	}
+
+	if configMng.HasErrors(problems) {
+		configMng.LogColourPrint("The config file has problems:", "red")
+		configMng.PrintProblems(problems)
+
+		if !configMng.Confirm("Repair it? The broken fields go back to their defaults") {
+			configMng.LogColourPrint("Fix "+configMng.ConfigPath+" and check it with 'tjournal config validate'", "yellow")
+			return false
+		}
+		if _, err := configMng.RepairConfig(); err != nil {
+			configMng.LogColourPrint("Error repairing config: "+err.Error(), "red")
+			return false
+		}
+		configMng.LogColourPrint("Config repaired", "green")
+	}
+
+	if err := configMng.MigrateConfigFile(); err != nil {
+		configMng.LogColourPrint("Error updating the config format: "+err.Error(), "yellow")
+	}
+	return true
+}
+
+const debugUsage = `Usage: 'tjournal.exe debug logs [--all] [-n LINES]'
+
+Prints the last 50 lines of the activity log, -n changes how many, 0 for everything.
+--all includes the rotated older files.`
+
+func showActivity(args []string) {
+	lines := 50
+	all := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--all", "-all":
+			all = true
+		case "-n", "--lines", "-lines":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					configMng.LogColourPrint("-n needs a number", "red")
+					return
+				}
+				lines = n
+				i++
+			}
+		}
+	}
+
+	activity, err := logging.Tail(activityPath, lines, all)
+	if err != nil {
+		configMng.LogColourPrint("Error reading the activity log: "+err.Error(), "red")
+		return
+	}
+	if len(activity) == 0 {
+		fmt.Println("Nothing logged yet")
+		return
+	}
+
+	fmt.Println("# " + activityPath)
+	for _, line := range activity {
+		fmt.Println(line)
+	}
+}
+
+// Sends a new log, or queues it when offline
+func saveLog(ctx context.Context, online bool, journal *api.JournalDB, newLog api.CreateJournalLogReq) {
+	if !online {
+		_, err := cache.Update(func(c *cache.Cache) {
+			c.Queue(newLog)
+		})
+		if err != nil {
+			configMng.LogColourPrint("Error queueing log: "+err.Error(), "red")
+			return
+		}
+		appLog.Info("log queued while offline")
+		configMng.LogColourPrint("Offline, log queued and will be sent next time the server is reachable\n", "yellow")
+		return
+	}
+
+	if err := journal.CreateJournalLog(ctx, newLog.Log, newLog.Title, &newLog.Tags); err != nil {
+		printAPIError("Error creating log", err)
+		return
+	}
+	if err := cache.MarkStale(); err != nil {
+		configMng.LogColourPrint("Error updating the local cache: "+err.Error(), "yellow")
+	}
+	appLog.Info("log saved")
+	configMng.LogColourPrint("All good pardner 🤠\n", "green")
+}
+
+// Fills the named template and lets the user finish it in the editor. Extra text goes at the end of the body.
+func fromTemplate(config *configMng.LocalConfig, name string, extra string) (api.CreateJournalLogReq, bool) {
+	tmpl, err := templates.Load(name)
+	if err != nil {
+		configMng.LogColourPrint(err.Error(), "red")
+		return api.CreateJournalLogReq{}, false
+	}
+
+	filled, err := tmpl.Fill(templates.Vars{Now: time.Now(), Prompt: configMng.Ask})
+	if err != nil {
+		configMng.LogColourPrint("Error filling template: "+err.Error(), "red")
+		return api.CreateJournalLogReq{}, false
+	}
+	if extra != "" {
+		filled.Body = strings.TrimRight(filled.Body, "\n") + "\n" + extra + "\n"
+	}
+
+	if !NoEdit {
+		if filled, err = editTemplate(config, filled); err != nil {
+			configMng.LogColourPrint(err.Error(), "red")
+			return api.CreateJournalLogReq{}, false
+		}
+	}
+
+	// A template without a header keeps the defaults from `new`
+	title := strings.TrimSpace(filled.Title)
+	if title == "" {
+		title = "Quick Log"
+	}
+	tags := filled.Tags
+	if len(tags) == 0 {
+		tags = quickTags(config)
+	}
+	return api.CreateJournalLogReq{Log: strings.TrimSpace(filled.Body), Title: title, Tags: tags}, true
+}
+
+// Round trip through a temp file, the header stays editable so title and tags can change too
+func editTemplate(config *configMng.LocalConfig, filled templates.Template) (templates.Template, error) {
+	tmp, err := os.CreateTemp("", "tjournal-*.txt")
+	if err != nil {
+		return filled, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(filled.String()); err != nil {
+		tmp.Close()
+		return filled, err
+	}
+	tmp.Close()
+
+	if err := configMng.EditFile(config, tmp.Name()); err != nil {
+		return filled, err
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return filled, err
+	}
+	return templates.Parse(string(edited))
+}
+
+// Logs fetched to find today's or the latest entry, newest first so those are always in it
+const recentPage = 50
+
+// The `daily` command. Today's daily entry gets appended to rather than doubled up, if the user wants.
+func daily(ctx context.Context, online bool, journal *api.JournalDB, localCache *cache.Cache, config *configMng.LocalConfig, activeTheme theme.Theme) {
+	existing := todaysDaily(ctx, online, journal, localCache)
+
+	appending := false
+	note := ""
+	if existing != nil {
+		configMng.LogColourPrint("You already wrote today's daily: "+existing.Title, "yellow")
+		if online {
+			appending = configMng.Confirm("Add these answers to it?")
+		} else {
+			configMng.LogColourPrint("Offline, it can't be added to until the server is reachable", "yellow")
+		}
+		if !appending && !configMng.Confirm("Write a separate daily entry?") {
+			return
+		}
+		if appending {
+			note = "Adding to " + existing.Title
+		}
+	}
+
+	questions := config.DailyPrompts()
+	answers, ok, err := ui.RunDaily(ctx, questions, activeTheme, note)
+	if err != nil {
+		configMng.LogColourPrint(err.Error(), "red")
+		return
+	}
+	if !ok {
+		fmt.Println("Cancelled")
+		return
+	}
+
+	body := dailyEntry(questions, answers)
+	if body == "" {
+		fmt.Println("No answers, nothing saved")
+		return
+	}
+
+	if !appending {
+		title := "Daily " + time.Now().Format("2006-01-02")
+		saveLog(ctx, online, journal, api.CreateJournalLogReq{Log: body, Title: title, Tags: []string{"daily"}})
+		return
+	}
+
+	existing.Log = strings.TrimRight(existing.Log, "\n") + "\n\n" + "Update " + time.Now().Format("15:04") + "\n\n" + body
+	if err := journal.UpdateJournalLog(ctx, existing); err != nil {
+		printAPIError("Error updating today's daily", err)
+		return
+	}
+	if err := cache.StorePage([]api.ReadJournalLogRes{*existing}); err != nil {
+		configMng.LogColourPrint("Error updating the local cache: "+err.Error(), "yellow")
+	}
+	appLog.Info("daily appended", "log_id", existing.Log_Id)
+	configMng.LogColourPrint("Added to today's daily", "green")
+}
+
+// Today's entry tagged daily, nil if there isn't one. Falls back on the cache when offline.
+func todaysDaily(ctx context.Context, online bool, journal *api.JournalDB, localCache *cache.Cache) *api.ReadJournalLogRes {
+	logs := localCache.Logs
+	if online {
+		// Newest first, so today's is in the first page if it exists
+		if page, err := journal.ReadJournalLogsPage(ctx, recentPage, 0); err == nil {
+			logs = *page
+		}
+	}
+
+	dailyTags := []string{"daily"}
+	if journal.Cipher != nil {
+		// Hashed tags come back hashed
+		dailyTags = append(dailyTags, journal.Cipher.Tag("daily"))
+	}
+
+	now := time.Now()
+	for i := range logs {
+		created, ok := logs[i].CreatedTime()
+		if !ok || !sameDay(created, now) {
+			continue
+		}
+		for _, tag := range logs[i].Tags {
+			if slices.Contains(dailyTags, tag) {
+				found := logs[i]
+				return &found
+			}
+		}
+	}
+	return nil
+}
+
+// The `append` command. Adds a timestamped line so a day or an incident can stay in one entry.
+func appendLog(ctx context.Context, online bool, journal *api.JournalDB, localCache *cache.Cache, target string, text string) {
+	if strings.TrimSpace(text) == "" {
+		fmt.Println("Need text to append")
+		return
+	}
+	if !online {
+		configMng.LogColourPrint("Server unreachable, appending needs to be online", "red")
+		return
+	}
+
+	// last and today are always on the first page, an older id needs everything
+	page, err := journal.ReadJournalLogsPage(ctx, recentPage, 0)
+	if err != nil {
+		printAPIError("Error reading logs", err)
+		return
+	}
+	entry, err := findLog(*page, target)
+	if _, isID := strconv.Atoi(target); entry == nil && isID == nil && len(*page) == recentPage {
+		logs, readErr := readAllLogs(ctx, online, journal, localCache)
+		if readErr != nil {
+			printAPIError("Error reading logs", readErr)
+			return
+		}
+		entry, err = findLog(*logs, target)
+	}
+	if err != nil {
+		configMng.LogColourPrint(err.Error(), "red")
+		return
+	}
+
+	line := time.Now().Format("2006-01-02 15:04") + " " + text
+	if entry.Log = strings.TrimRight(entry.Log, "\n"); entry.Log != "" {
+		line = "\n" + line
+	}
+	entry.Log += line
+
+	if err := journal.UpdateJournalLog(ctx, entry); err != nil {
+		printAPIError("Error appending to the log", err)
+		return
+	}
+	if err := cache.StorePage([]api.ReadJournalLogRes{*entry}); err != nil {
+		configMng.LogColourPrint("Error updating the local cache: "+err.Error(), "yellow")
+	}
+	appLog.Info("appended", "log_id", entry.Log_Id)
+	configMng.LogColourPrint("Appended to "+entry.Title, "green")
+}
+
+// A log by id, or "today" for today's newest and "last" for the newest. logs are newest first.
+func findLog(logs []api.ReadJournalLogRes, target string) (*api.ReadJournalLogRes, error) {
+	switch target {
+	case "last":
+		if len(logs) == 0 {
+			return nil, errors.New("No logs yet")
+		}
+		found := logs[0]
+		return &found, nil
+
+	case "today":
+		now := time.Now()
+		for _, log := range logs {
+			if created, ok := log.CreatedTime(); ok && sameDay(created, now) {
+				return &log, nil
+			}
+		}
+		return nil, errors.New("Nothing written today yet")
+	}
+
+	id, err := strconv.Atoi(target)
+	if err != nil {
+		return nil, fmt.Errorf("Expected a log id, today or last, got %q", target)
+	}
+	for _, log := range logs {
+		if log.Log_Id == id {
+			return &log, nil
+		}
+	}
+	return nil, fmt.Errorf("No log with id %d", id)
+}
+
+func sameDay(a time.Time, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// Each question with its answer under it, skipped ones left out
+func dailyEntry(questions []string, answers []string) string {
+	var b strings.Builder
+	for i, question := range questions {
+		if answers[i] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "%s\n%s\n\n", question, answers[i])
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Tags for `new`, from the config or "quick"
+func quickTags(config *configMng.LocalConfig) []string {
+	if len(config.DefaultTags) > 0 {
+		return config.DefaultTags
+	}
+	return []string{"quick"}
+}
+
+// The login command. Asks for whatever wasn't passed in.
+func login(ctx context.Context, online bool) {
+	if !online {
+		configMng.LogColourPrint("Server unreachable. You need to be online to log in.", "red")
+		return
+	}
+
+	email := LoginEmail
+	var password string
+	if PasswordStdin {
+		if email == "" {
+			configMng.LogColourPrint("--password-stdin needs the email as an argument", "red")
+			return
+		}
+		var err error
+		if password, err = configMng.ReadPasswordStdin(); err != nil {
+			configMng.LogColourPrint(err.Error(), "red")
+			return
+		}
+	} else {
+		if email == "" {
+			email = configMng.ScanEmail()
+		}
+		password = configMng.ScanPassword(email)
+	}
+
+	previous := ""
+	if old, err := configMng.ReadConfig(); err == nil {
+		previous = old.Username
+	}
+
+	config, err := configMng.Login(ctx, base+LoginRoute, email, password)
+	if err != nil {
+		configMng.LogColourPrint(err.Error(), "red")
+		return
+	}
+
+	// A cache left by someone else. Older caches don't say whose they are, the config before this login does.
+	if cached, err := cache.Load(); err == nil {
+		owner := cached.Owner
+		if owner == "" {
+			owner = previous
+		}
+		if owner != config.Username {
+			clearCache()
+		}
+	}
+	appLog.Info("logged in", "username", config.Username)
+	configMng.LogColourPrint("Logged in as "+config.Username, "green")
+}
+
+// Prints an api error, with a hint when the saved login is no good anymore
+func printAPIError(context string, err error) {
+	appLog.Error(context, "err", err)
+	configMng.LogColourPrint(context+": "+err.Error(), "red")
+	if errors.Is(err, api.ErrUnauthorized) {
+		configMng.LogColourPrint("Couldn't log in again, run 'tjournal login' to sign in with your password", "yellow")
+	}
+}
+
+// Send the logs written while offline. The ones that fail stay queued.
+// The cache stays locked while sending so a second tjournal can't send the same logs.
+func flushPending(ctx context.Context, journal *api.JournalDB, localCache *cache.Cache) {
+	var sent int
+	var failed []api.CreateJournalLogReq
+	updated, err := cache.Update(func(c *cache.Cache) {
+		for _, pending := range c.Pending {
+			if err := journal.CreateJournalLog(ctx, pending.Log, pending.Title, &pending.Tags); err != nil {
+				appLog.Warn("pending log not sent", "err", err)
+				failed = append(failed, pending)
+			}
+		}
+		sent = len(c.Pending) - len(failed)
+		c.Pending = failed
+		if sent > 0 {
+			c.Stale = true
+		}
+	})
+	if err != nil {
+		configMng.LogColourPrint("Error updating the local cache: "+err.Error(), "yellow")
+	}
+	if updated != nil {
+		*localCache = *updated
+	}
+
+	if sent > 0 {
+		configMng.LogColourPrint(fmt.Sprintf("Sent %d log(s) written while offline", sent), "green")
+	}
+	if len(failed) > 0 {
+		configMng.LogColourPrint(fmt.Sprintf("%d offline log(s) still queued", len(failed)), "yellow")
+	}
+}
+
 func main() {
 	// ConfigBusiness
 	if false {
@@ -79,9 +1027,14 @@ func main() {
 	configJsonPath := filepath.Join(exeDir, configName)
 
 	configMng.ConfigPath = configJsonPath
+	cache.CachePath = filepath.Join(exeDir, cacheName)
+	configMng.Secrets = secret.NewFileStore(filepath.Join(exeDir, tokenName), configMng.Passphrase)
+	activityPath = filepath.Join(exeDir, activityName)
+	templates.Dir = filepath.Join(exeDir, "templates")
 
-	if len(os.Args) > 1 {
-		handleCLIArg(os.Args[1:])
+	args := parseLogFlags(os.Args[1:])
+	if len(args) > 0 {
+		handleCLIArg(args)
 		if return_flag {
 			return
 		}
@@ -90,39 +1043,158 @@ func main() {
 		AppState = "tui_view"
 	}
 
-	// Check internet and server status
-	if connStatus := api.UserIsConnected(); !connStatus {
-		configMng.LogColourPrint("No internet", "red")
+	if configMng.ConfigFileExists() && !checkConfig() {
 		return
 	}
 
-	status, err := api.CheckServerStatus(base + PingRoute)
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// The HTTP client is needed before login, so apply its settings from an existing config up front
+	wakeTimeout := defaultWakeTimeout
+	if configMng.ConfigFileExists() {
+		if config, err := configMng.ReadConfig(); err == nil {
+			cache.User = config.Username
+			if LogOpts.File == "" {
+				LogOpts.File = config.LogFile
+			}
+			api.Configure(config.ClientConfig())
+			api.SetRetryPolicy(config.RetryPolicy())
+			if config.WakeTimeout > 0 {
+				wakeTimeout = time.Duration(config.WakeTimeout) * time.Second
+			}
+			if config.Server != "" {
+				base = strings.TrimRight(config.Server, "/")
+			}
+			if config.Timezone != "" {
+				if loc, err := time.LoadLocation(config.Timezone); err == nil {
+					time.Local = loc
+				}
+			}
+			if config.Output == "json" {
+				OutputJSON = true
+			}
+		}
+	}
+
+	LogOpts.Quiet = AppState == "tui_view"
+	LogOpts.ActivityPath = activityPath
+	logger, closeLog, err := logging.New(LogOpts)
 	if err != nil {
-		configMng.LogColourPrint(err.Error(), "yellow")
+		configMng.LogColourPrint("Error opening log file: "+err.Error(), "red")
 		return
 	}
+	defer closeLog()
+	api.SetLogger(logger)
+	appLog = logger
+	appLog.Info("run", "command", AppState)
 
-	if !status {
-		configMng.LogColourPrint("Server Offline", "red")
+	// Reachability is decided by the server's own ping, not some third party host
+	// A search the cache can answer doesn't wait on a sleeping server
+	needServer := !(AppState == "search" && searchFromCache())
+	online := false
+	if needServer {
+		online = waitForServer(ctx, wakeTimeout)
+	}
+	if ctx.Err() != nil {
+		// Ctrl+C while waiting, not an unreachable server
+		appLog.Info("cancelled while waiting for the server")
+		return
+	}
+
+	switch AppState {
+	case "login":
+		login(ctx, online)
+		return
+	case "logout":
+		if !discardPending() {
+			return
+		}
+		if err := configMng.Logout(ctx, base+LogoutRoute, online); err != nil {
+			configMng.LogColourPrint(err.Error(), "red")
+			return
+		}
+		clearCache()
+		appLog.Info("logged out")
+		configMng.LogColourPrint("Logged out", "green")
 		return
 	}
 
+	if !online {
+		if !configMng.ConfigFileExists() {
+			configMng.LogColourPrint("Server unreachable. You need to be online to log in the first time.", "red")
+			return
+		}
+		if needServer {
+			configMng.LogColourPrint("Server unreachable, working offline", "yellow")
+		}
+	}
+
 	// If any error, prints it and throws nil
-	config, err := configMng.ConfigBusiness(configName, base+LoginRoute)
+	var config *configMng.LocalConfig
+	if online {
+		config, err = configMng.ConfigBusiness(ctx, configName, base+LoginRoute)
+		if err == nil {
+			// Offline runs never touch the server so only need the token when online
+			err = config.LoadToken()
+		}
+	} else {
+		config, err = configMng.ReadConfig()
+		if err == nil && !config.LoggedIn() {
+			err = errors.New("Logged out. You need to be online to log in again.")
+		}
+	}
 	if err != nil {
 		configMng.LogColourPrint(err.Error(), "red")
 		return
 	}
 
+	activeTheme, err := theme.Resolve(config.Theme, config.Themes)
+	if err != nil {
+		configMng.LogColourPrint("Invalid theme in config: "+err.Error(), "yellow")
+		activeTheme = theme.Default()
+	}
+	theme.Set(activeTheme)
+
 	journalManage := api.JournalDB{Url: base + JournRoute, Username: config.Username, Token: config.Token}
+	// An expired token asks for the password again instead of failing
+	journalManage.Reauth = configMng.Reauthenticator(config, base+LoginRoute)
+	if config.E2E && online {
+		entryCipher, err := config.EntryCipher()
+		if err != nil {
+			configMng.LogColourPrint("End to end encryption: "+err.Error(), "red")
+			return
+		}
+		journalManage.Cipher = entryCipher
+	}
+
+	cache.User = config.Username
+	localCache, err := cache.Load()
+	if err != nil {
+		configMng.LogColourPrint("Error reading the local cache, starting a new one: "+err.Error(), "yellow")
+	}
+
+	if online && len(localCache.Pending) > 0 {
+		flushPending(ctx, &journalManage, localCache)
+	}
+
 	switch AppState {
 	case "quick_view":
-		fmt.Println("Quick View")
-		logs, err := journalManage.ReadJournalLogs()
+		logs, err := readAllLogs(ctx, online, &journalManage, localCache)
 		if err != nil {
-			configMng.LogColourPrint(err.Error(), "red")
+			printAPIError("Error reading logs", err)
 			return
 		}
+		if OutputJSON {
+			out, err := json.MarshalIndent(logs, "", "    ")
+			if err != nil {
+				configMng.LogColourPrint(err.Error(), "red")
+				return
+			}
+			fmt.Println(string(out))
+			return
+		}
+		fmt.Println("Quick View")
 		fmt.Println("")
 		for _, log := range *logs {
 			fmt.Println(log.Title)
@@ -132,31 +1204,92 @@ func main() {
 		}
 
 	case "quick_save":
-		if NewLogMessage == "" {
+		newLog := api.CreateJournalLogReq{Log: NewLogMessage, Title: "Quick Log", Tags: quickTags(config)}
+		if NewTemplate != "" {
+			var ok bool
+			if newLog, ok = fromTemplate(config, NewTemplate, NewLogMessage); !ok {
+				return
+			}
+		}
+
+		if strings.TrimSpace(newLog.Log) == "" {
 			fmt.Println("Need log")
+			return
 		}
-		if NewLogMessage != "" {
-			journMsg, err := journalManage.CreateJournalLog(NewLogMessage, "Quick Log", &[]string{"quick"})
+		saveLog(ctx, online, &journalManage, newLog)
+
+	case "daily":
+		daily(ctx, online, &journalManage, localCache, config, activeTheme)
+
+	case "append":
+		appendLog(ctx, online, &journalManage, localCache, AppendTarget, NewLogMessage)
+
+	case "search":
+		searchLogs(ctx, online, &journalManage, localCache, SearchQuery, SearchLimit)
+
+	case "tui_view":
+		if config != nil {
+			keys, err := bubble_init.LoadKeymap(config.Keys)
 			if err != nil {
-				configMng.LogColourPrint("Error creating log\n", "red")
-			}
-			if journMsg.Code != 201 {
-				configMng.LogColourPrint(journMsg.Message+"\n", "red")
+				configMng.LogColourPrint("Invalid keys in config: "+err.Error(), "red")
 				return
+			}
 
-			} else {
-				configMng.LogColourPrint("All good pardner 🤠\n", "green")
+			opts := ui.Options{
+				Keys:            keys,
+				Theme:           activeTheme,
+				RefreshInterval: time.Duration(config.RefreshInterval) * time.Second,
+				PageSize:        config.PageSize,
+				Offline:         !online,
+				Cache:           localCache,
+			}
+			if opts.PageSize <= 0 {
+				opts.PageSize = ui.DefaultPageSize
 			}
-		}
 
-	case "tui_view":
-		if config != nil {
-			if err := ui.InitRun(journalManage); err != nil {
+			if online {
+				// The TUI owns the terminal and can't ask for a password, so its first page is fetched here
+				// where logging in again still can. Other errors are left for the TUI to show.
+				first, err := journalManage.ReadJournalLogsPage(ctx, opts.PageSize, 0)
+				if errors.Is(err, api.ErrUnauthorized) {
+					printAPIError("Error logging in", err)
+					return
+				}
+				if err == nil {
+					opts.FirstPage = first
+					if err := cache.StorePage(*first); err != nil {
+						appLog.Warn("caching the first page", "err", err)
+					}
+				}
+				journalManage.Reauth = nil
+			}
+
+			if err := ui.InitRun(ctx, journalManage, opts); err != nil {
 				configMng.LogColourPrint(err.Error(), "red")
 				return
 			}
 		}
 
+	case "stats":
+		logs, err := readAllLogs(ctx, online, &journalManage, localCache)
+		if err != nil {
+			printAPIError("Error reading logs", err)
+			return
+		}
+
+		summary := stats.Compute(*logs, time.Now())
+		if !OutputJSON {
+			fmt.Print(summary)
+			return
+		}
+
+		out, err := json.MarshalIndent(summary, "", "    ")
+		if err != nil {
+			configMng.LogColourPrint(err.Error(), "red")
+			return
+		}
+		fmt.Println(string(out))
+
 	case "tui_save":
 		fmt.Println("TUI Save")
 	}
@@ -0,0 +1,282 @@
+// Full text search over the journal. The index is built from the logs in memory,
+// which for a few thousand entries takes a few milliseconds, so it isn't kept on disk.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	api "github.com/apooravm/tjournal/src/api"
+	"github.com/apooravm/tjournal/src/secret"
+)
+
+type field int
+
+const (
+	fieldTitle field = iota
+	fieldBody
+	fieldTags
+	numFields
+)
+
+// A hit in the title counts more than one in the body
+var fieldWeight = [numFields]float64{fieldTitle: 2, fieldBody: 1, fieldTags: 1.5}
+
+// BM25 parameters, the usual defaults
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Where a term shows up in one log, word positions per field for phrase queries
+type occurrence struct {
+	positions [numFields][]int
+}
+
+type Index struct {
+	logs []api.ReadJournalLogRes
+	// Words in each field of each log
+	lengths [][numFields]int
+	avgLen  [numFields]float64
+	// term -> log -> where
+	terms map[string]map[int]*occurrence
+	// Every term, sorted, so prefix queries can binary search
+	vocab []string
+}
+
+type Result struct {
+	Log   api.ReadJournalLogRes `json:"log"`
+	Score float64               `json:"score"`
+}
+
+// Indexes title, body and tags. Order is kept, ties in score go to the earlier log.
+func Build(logs []api.ReadJournalLogRes) *Index {
+	ix := &Index{
+		logs:    logs,
+		lengths: make([][numFields]int, len(logs)),
+		terms:   make(map[string]map[int]*occurrence),
+	}
+
+	var totals [numFields]int
+	for doc, log := range logs {
+		fields := [numFields][]string{
+			fieldTitle: Tokenize(log.Title),
+			fieldBody:  Tokenize(log.Log),
+			fieldTags:  tagTokens(log.Tags),
+		}
+
+		for f, words := range fields {
+			ix.lengths[doc][f] = len(words)
+			totals[f] += len(words)
+
+			for pos, word := range words {
+				docs, ok := ix.terms[word]
+				if !ok {
+					docs = make(map[int]*occurrence)
+					ix.terms[word] = docs
+				}
+				occ, ok := docs[doc]
+				if !ok {
+					occ = &occurrence{}
+					docs[doc] = occ
+				}
+				occ.positions[f] = append(occ.positions[f], pos)
+			}
+		}
+	}
+
+	if len(logs) > 0 {
+		for f := range totals {
+			ix.avgLen[f] = float64(totals[f]) / float64(len(logs))
+		}
+	}
+
+	ix.vocab = make([]string, 0, len(ix.terms))
+	for term := range ix.terms {
+		ix.vocab = append(ix.vocab, term)
+	}
+	sort.Strings(ix.vocab)
+
+	return ix
+}
+
+func (ix *Index) Len() int {
+	return len(ix.logs)
+}
+
+// Parses and runs the query, best match first
+func (ix *Index) Search(query string) ([]Result, error) {
+	q, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return ix.Run(q), nil
+}
+
+func (ix *Index) Run(q *Query) []Result {
+	scores := q.root.eval(ix)
+
+	results := make([]Result, 0, len(scores))
+	docs := make([]int, 0, len(scores))
+	for doc := range scores {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if scores[docs[i]] != scores[docs[j]] {
+			return scores[docs[i]] > scores[docs[j]]
+		}
+		return docs[i] < docs[j]
+	})
+
+	for _, doc := range docs {
+		results = append(results, Result{Log: ix.logs[doc], Score: math.Round(scores[doc]*1000) / 1000})
+	}
+	return results
+}
+
+// Lowercased words, split on anything that isn't a letter or a digit
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func tagTokens(tags []string) []string {
+	var words []string
+	for _, tag := range tags {
+		// Hashed e2e tags are meaningless to search
+		if secret.IsHashedTag(tag) {
+			continue
+		}
+		words = append(words, Tokenize(tag)...)
+	}
+	return words
+}
+
+// Terms starting with prefix
+func (ix *Index) expand(prefix string) []string {
+	start := sort.SearchStrings(ix.vocab, prefix)
+	var terms []string
+	for _, term := range ix.vocab[start:] {
+		if !strings.HasPrefix(term, prefix) {
+			break
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// BM25 score of every log containing term, each field weighted
+func (ix *Index) score(term string) map[int]float64 {
+	docs := ix.terms[term]
+	scores := make(map[int]float64, len(docs))
+	if len(docs) == 0 {
+		return scores
+	}
+
+	n := float64(len(ix.logs))
+	df := float64(len(docs))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+	for doc, occ := range docs {
+		var total float64
+		for f := field(0); f < numFields; f++ {
+			tf := float64(len(occ.positions[f]))
+			if tf == 0 {
+				continue
+			}
+			norm := 1 - b
+			if ix.avgLen[f] > 0 {
+				norm += b * float64(ix.lengths[doc][f]) / ix.avgLen[f]
+			}
+			total += fieldWeight[f] * tf * (k1 + 1) / (tf + k1*norm)
+		}
+		scores[doc] = idf * total
+	}
+	return scores
+}
+
+// Logs where the words appear one after the other in the same field
+func (ix *Index) phrase(words []string) map[int]float64 {
+	first := ix.terms[words[0]]
+	matches := make(map[int]float64)
+
+	for doc, occ := range first {
+		count := 0
+		for f := field(0); f < numFields; f++ {
+			for _, start := range occ.positions[f] {
+				if ix.followedBy(doc, f, start, words[1:]) {
+					count++
+				}
+			}
+		}
+		if count > 0 {
+			matches[doc] = float64(count)
+		}
+	}
+
+	if len(matches) == 0 {
+		return matches
+	}
+
+	// Score like the words on their own, plus a bit for how often the phrase itself shows up
+	for _, word := range words {
+		for doc, s := range ix.score(word) {
+			if _, ok := matches[doc]; ok {
+				matches[doc] += s
+			}
+		}
+	}
+	return matches
+}
+
+func (ix *Index) followedBy(doc int, f field, start int, rest []string) bool {
+	for i, word := range rest {
+		occ, ok := ix.terms[word][doc]
+		if !ok {
+			return false
+		}
+		positions := occ.positions[f]
+		at := sort.SearchInts(positions, start+i+1)
+		if at == len(positions) || positions[at] != start+i+1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (ix *Index) all() map[int]float64 {
+	docs := make(map[int]float64, len(ix.logs))
+	for doc := range ix.logs {
+		docs[doc] = 0
+	}
+	return docs
+}
+
+// A few words of text around the first word the query matched, for printing results
+func Snippet(text string, q *Query, width int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len([]rune(text)) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	start := 0
+	if at := q.firstMatch(text); at >= 0 {
+		// Back up a little for context
+		start = max(at-width/4, 0)
+	}
+	end := min(start+width, len(runes))
+	start = max(end-width, 0)
+
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
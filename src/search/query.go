@@ -0,0 +1,391 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query syntax:
+//
+//	standup notes       both words, anywhere
+//	"standup notes"     the exact phrase
+//	stand*              any word starting with stand
+//	standup OR retro    either
+//	NOT retro, -retro   without
+//	(a OR b) c          grouping
+//
+// AND, OR and NOT have to be uppercase, lowercase they're just words.
+type Query struct {
+	root node
+	// Words that count towards a match, for snippets
+	terms []queryTerm
+}
+
+type queryTerm struct {
+	word   string
+	prefix bool
+}
+
+var ErrEmptyQuery = errors.New("empty search")
+
+type node interface {
+	eval(ix *Index) map[int]float64
+}
+
+type termNode struct {
+	word   string
+	prefix bool
+}
+
+type phraseNode struct {
+	words []string
+}
+
+type andNode struct {
+	children []node
+}
+
+type orNode struct {
+	children []node
+}
+
+type notNode struct {
+	child node
+}
+
+func (n termNode) eval(ix *Index) map[int]float64 {
+	if !n.prefix {
+		return ix.score(n.word)
+	}
+
+	scores := make(map[int]float64)
+	for _, term := range ix.expand(n.word) {
+		for doc, s := range ix.score(term) {
+			scores[doc] += s
+		}
+	}
+	return scores
+}
+
+func (n phraseNode) eval(ix *Index) map[int]float64 {
+	return ix.phrase(n.words)
+}
+
+// Logs matching every child, scores added up. Only NOTs means everything but those.
+func (n andNode) eval(ix *Index) map[int]float64 {
+	var scores map[int]float64
+	var excluded []map[int]float64
+
+	for _, child := range n.children {
+		if not, ok := child.(notNode); ok {
+			excluded = append(excluded, not.child.eval(ix))
+			continue
+		}
+
+		matched := child.eval(ix)
+		if scores == nil {
+			scores = matched
+			continue
+		}
+		for doc, s := range scores {
+			if m, ok := matched[doc]; ok {
+				scores[doc] = s + m
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	if scores == nil {
+		scores = ix.all()
+	}
+	for _, docs := range excluded {
+		for doc := range docs {
+			delete(scores, doc)
+		}
+	}
+	return scores
+}
+
+func (n orNode) eval(ix *Index) map[int]float64 {
+	scores := make(map[int]float64)
+	for _, child := range n.children {
+		for doc, s := range child.eval(ix) {
+			scores[doc] += s
+		}
+	}
+	return scores
+}
+
+func (n notNode) eval(ix *Index) map[int]float64 {
+	return andNode{children: []node{n}}.eval(ix)
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokPhrase
+	tokAnd
+	tokOr
+	tokNot
+	tokOpen
+	tokClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func lex(query string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokOpen})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokClose})
+			i++
+
+		case r == '-' && i+1 < len(query) && !unicode.IsSpace(rune(query[i+1])):
+			// -word, -"phrase" and -(group) all mean NOT
+			tokens = append(tokens, token{kind: tokNot})
+			i++
+
+		case r == '"':
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("missing closing quote")
+			}
+			tokens = append(tokens, token{kind: tokPhrase, text: query[i+1 : i+1+end]})
+			i += end + 2
+
+		default:
+			end := strings.IndexFunc(query[i:], func(r rune) bool {
+				return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+			})
+			if end < 0 {
+				end = len(query) - i
+			}
+			word := query[i : i+end]
+			i += end
+
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokAnd})
+			case "OR":
+				tokens = append(tokens, token{kind: tokOr})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokNot})
+			default:
+				tokens = append(tokens, token{kind: tokWord, text: word})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	terms  []queryTerm
+	// Inside a NOT, so the words don't go in terms
+	negated int
+}
+
+func Parse(query string) (*Query, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		// The only thing or() stops at early is a stray )
+		return nil, errors.New("unexpected )")
+	}
+	if root == nil {
+		return nil, ErrEmptyQuery
+	}
+	return &Query{root: root, terms: p.terms}, nil
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// or := and ("OR" and)*
+func (p *parser) or() (node, error) {
+	var children []node
+	for {
+		child, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		tok, ok := p.peek()
+		if ok && tok.kind == tokOr {
+			if child == nil {
+				return nil, errors.New("OR needs something before it")
+			}
+			p.pos++
+			children = append(children, child)
+			continue
+		}
+
+		if child == nil {
+			if len(children) > 0 {
+				return nil, errors.New("OR needs something after it")
+			}
+			return nil, nil
+		}
+		children = append(children, child)
+		break
+	}
+
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return orNode{children: children}, nil
+}
+
+// and := unary (["AND"] unary)*, stops before OR and )
+func (p *parser) and() (node, error) {
+	var children []node
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind == tokOr || tok.kind == tokClose {
+			break
+		}
+		if tok.kind == tokAnd {
+			if len(children) == 0 {
+				return nil, errors.New("AND needs something before it")
+			}
+			p.pos++
+			if next, ok := p.peek(); !ok || next.kind == tokOr || next.kind == tokClose {
+				return nil, errors.New("AND needs something after it")
+			}
+			continue
+		}
+
+		child, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			children = append(children, child)
+		}
+	}
+
+	switch len(children) {
+	case 0:
+		return nil, nil
+	case 1:
+		return children[0], nil
+	}
+	return andNode{children: children}, nil
+}
+
+// unary := "NOT" unary | "(" or ")" | phrase | word
+// nil for words with nothing searchable in them, like a lone *
+func (p *parser) unary() (node, error) {
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case tokNot:
+		if next, ok := p.peek(); !ok || next.kind == tokOr || next.kind == tokAnd || next.kind == tokClose {
+			return nil, errors.New("NOT needs something after it")
+		}
+		p.negated++
+		child, err := p.unary()
+		p.negated--
+		if err != nil || child == nil {
+			return nil, err
+		}
+		return notNode{child: child}, nil
+
+	case tokOpen:
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || next.kind != tokClose {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		if inner == nil {
+			return nil, errors.New("empty ()")
+		}
+		return inner, nil
+
+	case tokPhrase:
+		return p.words(Tokenize(tok.text), false), nil
+
+	default:
+		prefix := strings.HasSuffix(tok.text, "*")
+		words := Tokenize(strings.TrimRight(tok.text, "*"))
+		// stand-up* is the phrase "stand up", a prefix only makes sense on a single word
+		return p.words(words, prefix && len(words) == 1), nil
+	}
+}
+
+// A term for one word, a phrase for more
+func (p *parser) words(words []string, prefix bool) node {
+	if p.negated == 0 {
+		for _, word := range words {
+			p.terms = append(p.terms, queryTerm{word: word, prefix: prefix})
+		}
+	}
+
+	switch len(words) {
+	case 0:
+		return nil
+	case 1:
+		return termNode{word: words[0], prefix: prefix}
+	}
+	return phraseNode{words: words}
+}
+
+// Rune offset of the first word in text the query looks for, -1 if none
+func (q *Query) firstMatch(text string) int {
+	runes := []rune(strings.ToLower(text))
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+
+		word := string(runes[start:i])
+		for _, term := range q.terms {
+			if word == term.word || (term.prefix && strings.HasPrefix(word, term.word)) {
+				return start
+			}
+		}
+		start = -1
+	}
+	return -1
+}
@@ -0,0 +1,122 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	api "github.com/apooravm/tjournal/src/api"
+)
+
+var testLogs = []api.ReadJournalLogRes{
+	{Log_Id: 1, Title: "Standup", Log: "notes from the standup, talked about the retro"},
+	{Log_Id: 2, Title: "Retro", Log: "retro went long", Tags: []string{"work"}},
+	{Log_Id: 3, Title: "Weekend", Log: "hiking and standing around", Tags: []string{"h:0123456789abcdef", "h:home"}},
+	{Log_Id: 4, Title: "Notes", Log: "notes standup from a different angle"},
+}
+
+// Log ids of the results, best first
+func ids(t *testing.T, ix *Index, query string) []int {
+	t.Helper()
+	results, err := ix.Search(query)
+	if err != nil {
+		t.Fatalf("Search(%q): %v", query, err)
+	}
+	var got []int
+	for _, result := range results {
+		got = append(got, result.Log.Log_Id)
+	}
+	return got
+}
+
+// Which logs match, order aside
+func TestSearch(t *testing.T) {
+	ix := Build(testLogs)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"standup notes", []int{1, 4}},
+		{`"standup notes"`, nil},
+		{`"notes standup"`, []int{4}},
+		{`"from the standup"`, []int{1}},
+		{"stand*", []int{1, 3, 4}},
+		{"retro OR hiking", []int{1, 2, 3}},
+		{"standup -retro", []int{4}},
+		{"standup NOT retro", []int{4}},
+		{"-standup", []int{2, 3}},
+		{"(retro OR hiking) AND work", []int{2}},
+		{"work", []int{2}},
+		{"0123456789abcdef", nil},
+		{"home", []int{3}},
+		{"and", []int{3}},
+		{"nothing", nil},
+	}
+
+	for _, tt := range tests {
+		got := ids(t, ix, tt.query)
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// The same word in the title beats it in the body
+func TestSearchTitleWeight(t *testing.T) {
+	ix := Build([]api.ReadJournalLogRes{
+		{Log_Id: 1, Title: "Monday", Log: "garden work"},
+		{Log_Id: 2, Title: "Garden", Log: "monday work"},
+	})
+	if got := ids(t, ix, "garden"); !slices.Equal(got, []int{2, 1}) {
+		t.Errorf("Search(garden) = %v, want the title match first", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{`"open`, "missing closing quote"},
+		{"a)", "unexpected )"},
+		{"(a", "missing )"},
+		{"()", "empty ()"},
+		{"OR a", "OR needs something before it"},
+		{"a OR", "OR needs something after it"},
+		{"AND a", "AND needs something before it"},
+		{"a AND", "AND needs something after it"},
+		{"a NOT", "NOT needs something after it"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.query, err, tt.err)
+		}
+	}
+
+	for _, query := range []string{"", "   ", "*"} {
+		if _, err := Parse(query); err != ErrEmptyQuery {
+			t.Errorf("Parse(%q) error = %v, want ErrEmptyQuery", query, err)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	q, err := Parse("retro")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Snippet("short   text", q, 40); got != "short text" {
+		t.Errorf("short text = %q", got)
+	}
+
+	text := "a long day of meetings and more meetings, then finally the retro where nothing got decided"
+	got := Snippet(text, q, 30)
+	if !slices.Contains(Tokenize(got), "retro") || !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("Snippet() = %q, want the middle around retro", got)
+	}
+}
@@ -0,0 +1,120 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Marks an encrypted field, anything without it was written before encryption was turned on
+const sealedPrefix = "tje1:"
+
+// Tags hashed with HashTags are this followed by hashedTagLen hex digits
+const (
+	HashedTagPrefix = "h:"
+	hashedTagLen    = 16
+)
+
+var ErrDecrypt = errors.New("can't decrypt entry, wrong journal passphrase?")
+
+// Encrypts journal entries on the client so the server only ever sees ciphertext
+type EntryCipher struct {
+	aead   cipher.AEAD
+	tagKey []byte
+	// Replace tags with keyed hashes instead of sending them as is.
+	// Equal tags still hash equal so filtering works, but the names stay private.
+	HashTags bool
+}
+
+// The key comes from the passphrase, salted with the username so it's the same on every device
+func NewEntryCipher(passphrase string, username string, hashTags bool) (*EntryCipher, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+
+	salt := sha256.Sum256([]byte("tjournal-e2e:" + username))
+	master := DeriveKey([]byte(passphrase), salt[:], iterations, keyLen)
+
+	block, err := aes.NewCipher(subkey(master, "entries"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EntryCipher{aead: aead, tagKey: subkey(master, "tags"), HashTags: hashTags}, nil
+}
+
+// Separate keys for separate jobs
+func subkey(master []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func (c *EntryCipher) Seal(plain string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Plain text from before encryption was turned on comes back untouched
+func (c *EntryCipher) Open(text string) (string, error) {
+	if !IsSealed(text) {
+		return text, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(text, sealedPrefix))
+	if err != nil || len(raw) < c.aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+
+	nonce, data := raw[:c.aead.NonceSize()], raw[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(plain), nil
+}
+
+// The tag as it should be sent
+func (c *EntryCipher) Tag(tag string) string {
+	if !c.HashTags || IsHashedTag(tag) {
+		return tag
+	}
+
+	mac := hmac.New(sha256.New, c.tagKey)
+	mac.Write([]byte(strings.ToLower(tag)))
+	return HashedTagPrefix + hex.EncodeToString(mac.Sum(nil))[:hashedTagLen]
+}
+
+// Whether the tag is one Tag hashed, not a plain tag like h:home
+func IsHashedTag(tag string) bool {
+	digits, ok := strings.CutPrefix(tag, HashedTagPrefix)
+	if !ok || len(digits) != hashedTagLen {
+		return false
+	}
+	for _, r := range digits {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func IsSealed(text string) bool {
+	return strings.HasPrefix(text, sealedPrefix)
+}
@@ -0,0 +1,212 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/apooravm/tjournal/src/fsutil"
+)
+
+var (
+	ErrNoSecret        = errors.New("no secret stored")
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted secret file")
+)
+
+// Somewhere to keep the login token out of the config. Swap in a keychain backed one if needed.
+type Store interface {
+	// ErrNoSecret when nothing was saved
+	Load() (string, error)
+	Save(secret string) error
+	Delete() error
+	Exists() bool
+}
+
+// Asks for the passphrase. confirm is true when a new secret is being written,
+// so a prompt can ask twice.
+type PassphraseFunc func(confirm bool) (string, error)
+
+// Keeps the secret in a file, encrypted with AES-256-GCM under a key derived from a passphrase
+type FileStore struct {
+	Path       string
+	Passphrase PassphraseFunc
+
+	// Asked for once per run
+	passphrase string
+}
+
+func NewFileStore(path string, passphrase PassphraseFunc) *FileStore {
+	return &FileStore{Path: path, Passphrase: passphrase}
+}
+
+// What goes on disk
+type sealed struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+const (
+	// OWASP's recommendation for PBKDF2-HMAC-SHA256
+	iterations = 600000
+	keyLen     = 32
+	saltLen    = 16
+)
+
+func (f *FileStore) Exists() bool {
+	_, err := os.Stat(f.Path)
+	return err == nil
+}
+
+func (f *FileStore) Load() (string, error) {
+	byteArr, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoSecret
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var s sealed
+	if err := json.Unmarshal(byteArr, &s); err != nil {
+		return "", ErrWrongPassphrase
+	}
+
+	pass, err := f.getPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(pass, s.Salt, s.Iterations)
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := gcm.Open(nil, s.Nonce, s.Data, nil)
+	if err != nil {
+		// Don't keep a passphrase that didn't work
+		f.passphrase = ""
+		return "", ErrWrongPassphrase
+	}
+
+	return string(plain), nil
+}
+
+func (f *FileStore) Save(secret string) error {
+	// Check a passphrase typed in here against the file it replaces, a typo would lock the user out.
+	// One that doesn't open it is taken as a new passphrase and asked for twice.
+	if f.passphrase == "" && f.Exists() {
+		if _, err := f.Load(); err != nil && !errors.Is(err, ErrWrongPassphrase) {
+			return err
+		}
+	}
+
+	pass, err := f.getPassphrase(true)
+	if err != nil {
+		return err
+	}
+
+	s := sealed{Version: 1, Iterations: iterations, Salt: make([]byte, saltLen)}
+	if _, err := rand.Read(s.Salt); err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(pass, s.Salt, s.Iterations)
+	if err != nil {
+		return err
+	}
+
+	s.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return err
+	}
+	s.Data = gcm.Seal(nil, s.Nonce, []byte(secret), nil)
+
+	byteArr, err := json.Marshal(&s)
+	if err != nil {
+		return err
+	}
+
+	return fsutil.WriteAtomic(f.Path, byteArr, 0600)
+}
+
+func (f *FileStore) Delete() error {
+	for _, path := range []string{f.Path, f.Path + ".bak"} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FileStore) getPassphrase(confirm bool) (string, error) {
+	if f.passphrase != "" {
+		return f.passphrase, nil
+	}
+	if f.Passphrase == nil {
+		return "", errors.New("no passphrase source")
+	}
+
+	pass, err := f.Passphrase(confirm)
+	if err != nil {
+		return "", err
+	}
+	if pass == "" {
+		return "", errors.New("empty passphrase")
+	}
+
+	f.passphrase = pass
+	return pass, nil
+}
+
+func newGCM(passphrase string, salt []byte, iter int) (cipher.AEAD, error) {
+	if iter <= 0 {
+		return nil, ErrWrongPassphrase
+	}
+
+	block, err := aes.NewCipher(DeriveKey([]byte(passphrase), salt, iter, keyLen))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PBKDF2 with HMAC-SHA256 (RFC 8018), the stdlib only got it in Go 1.24
+func DeriveKey(password []byte, salt []byte, iter int, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	buf := make([]byte, 4)
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u = prf.Sum(u[:0])
+
+		t := make([]byte, hashLen)
+		copy(t, u)
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
@@ -0,0 +1,96 @@
+package secret
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Test vectors for PBKDF2-HMAC-SHA256 from RFC 7914 section 11
+func TestDeriveKey(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iter           int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+
+	for _, tt := range tests {
+		got := hex.EncodeToString(DeriveKey([]byte(tt.password), []byte(tt.salt), tt.iter, 64))
+		if got != tt.want {
+			t.Errorf("DeriveKey(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iter, got, tt.want)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.enc")
+	passphrase := func(confirm bool) (string, error) { return "hunter2", nil }
+
+	store := NewFileStore(path, passphrase)
+	if _, err := store.Load(); !errors.Is(err, ErrNoSecret) {
+		t.Fatalf("Load() before Save = %v, want ErrNoSecret", err)
+	}
+
+	if err := store.Save("TOKEN"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewFileStore(path, passphrase).Load()
+	if err != nil || got != "TOKEN" {
+		t.Fatalf("Load() = %q, %v", got, err)
+	}
+
+	wrong := NewFileStore(path, func(confirm bool) (string, error) { return "nope", nil })
+	if _, err := wrong.Load(); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Load() with the wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+
+	if err := store.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if store.Exists() {
+		t.Error("secret still there after Delete")
+	}
+}
+
+// Saving over an existing file checks the passphrase first. One that doesn't open it is asked for again as a new one.
+func TestFileStoreSaveChecksPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.enc")
+	if err := NewFileStore(path, func(confirm bool) (string, error) { return "hunter2", nil }).Save("OLD"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		answers []string
+		asks    []bool
+		reads   string
+	}{
+		// Unlocks, so it's kept
+		{[]string{"hunter2"}, []bool{false}, "hunter2"},
+		// A typo isn't saved as is, the new passphrase is asked for with confirm
+		{[]string{"hunetr2", "fresh"}, []bool{false, true}, "fresh"},
+	}
+
+	for _, tt := range tests {
+		var asks []bool
+		store := NewFileStore(path, func(confirm bool) (string, error) {
+			asks = append(asks, confirm)
+			return tt.answers[len(asks)-1], nil
+		})
+		if err := store.Save("NEW"); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(asks, tt.asks) {
+			t.Errorf("answers %v: asked with confirm %v, want %v", tt.answers, asks, tt.asks)
+		}
+
+		got, err := NewFileStore(path, func(confirm bool) (string, error) { return tt.reads, nil }).Load()
+		if err != nil || got != "NEW" {
+			t.Errorf("answers %v: Load() with %q = %q, %v", tt.answers, tt.reads, got, err)
+		}
+	}
+}
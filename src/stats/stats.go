@@ -0,0 +1,211 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	api "github.com/apooravm/tjournal/src/api"
+)
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// Everything `tjournal stats` and the stats tab show
+type Summary struct {
+	TotalEntries int `json:"total_entries"`
+	ActiveDays   int `json:"active_days"`
+
+	PerDay   map[string]int `json:"per_day"`
+	PerWeek  map[string]int `json:"per_week"`
+	PerMonth map[string]int `json:"per_month"`
+
+	AvgPerDay   float64 `json:"avg_per_day"`
+	AvgPerWeek  float64 `json:"avg_per_week"`
+	AvgPerMonth float64 `json:"avg_per_month"`
+
+	CurrentStreak int `json:"current_streak"`
+	LongestStreak int `json:"longest_streak"`
+
+	TopTags []TagCount `json:"top_tags"`
+
+	TotalWords     int     `json:"total_words"`
+	AvgWords       float64 `json:"avg_words"`
+	AvgEntryLength float64 `json:"avg_entry_length"`
+
+	// Entries per hour of the day, 0 is midnight
+	ByHour    [24]int        `json:"by_hour"`
+	TimeOfDay map[string]int `json:"time_of_day"`
+}
+
+const (
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+	topTagCount = 10
+)
+
+func weekKey(t time.Time) string {
+	y, w := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", y, w)
+}
+
+func timeOfDay(hour int) string {
+	switch {
+	case hour >= 5 && hour < 12:
+		return "morning"
+	case hour >= 12 && hour < 17:
+		return "afternoon"
+	case hour >= 17 && hour < 22:
+		return "evening"
+	default:
+		return "night"
+	}
+}
+
+// Compute stats over the given logs. now decides whether the current streak is still alive.
+func Compute(logs []api.ReadJournalLogRes, now time.Time) Summary {
+	s := Summary{
+		PerDay:    make(map[string]int),
+		PerWeek:   make(map[string]int),
+		PerMonth:  make(map[string]int),
+		TimeOfDay: map[string]int{"morning": 0, "afternoon": 0, "evening": 0, "night": 0},
+		TopTags:   []TagCount{},
+	}
+
+	tagCounts := make(map[string]int)
+	totalChars := 0
+	var first time.Time
+
+	for _, log := range logs {
+		s.TotalEntries++
+		totalChars += utf8.RuneCountInString(log.Log)
+		s.TotalWords += len(strings.Fields(log.Log))
+
+		for _, tag := range log.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tagCounts[tag]++
+			}
+		}
+
+		created, ok := log.CreatedTime()
+		if !ok {
+			continue
+		}
+
+		if first.IsZero() || created.Before(first) {
+			first = created
+		}
+
+		s.PerDay[created.Format(dayLayout)]++
+		s.PerWeek[weekKey(created)]++
+		s.PerMonth[created.Format(monthLayout)]++
+		s.ByHour[created.Hour()]++
+		s.TimeOfDay[timeOfDay(created.Hour())]++
+	}
+
+	if s.TotalEntries > 0 {
+		s.AvgWords = float64(s.TotalWords) / float64(s.TotalEntries)
+		s.AvgEntryLength = float64(totalChars) / float64(s.TotalEntries)
+	}
+
+	s.ActiveDays = len(s.PerDay)
+
+	// Averages are over the whole span since the first entry, quiet days included
+	if !first.IsZero() {
+		days := int(truncateDay(now).Sub(truncateDay(first)).Hours()/24) + 1
+		days = max(days, 1)
+		s.AvgPerDay = float64(s.TotalEntries) / float64(days)
+		s.AvgPerWeek = s.AvgPerDay * 7
+		s.AvgPerMonth = s.AvgPerDay * 30
+	}
+
+	s.CurrentStreak, s.LongestStreak = streaks(s.PerDay, now)
+
+	for tag, count := range tagCounts {
+		s.TopTags = append(s.TopTags, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(s.TopTags, func(i, j int) bool {
+		if s.TopTags[i].Count != s.TopTags[j].Count {
+			return s.TopTags[i].Count > s.TopTags[j].Count
+		}
+		return s.TopTags[i].Tag < s.TopTags[j].Tag
+	})
+	if len(s.TopTags) > topTagCount {
+		s.TopTags = s.TopTags[:topTagCount]
+	}
+
+	return s
+}
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// Current and longest run of consecutive days with at least one entry.
+// A streak stays current until a full day is missed, so no entry yet today doesn't break it.
+func streaks(perDay map[string]int, now time.Time) (int, int) {
+	days := make([]time.Time, 0, len(perDay))
+	for key := range perDay {
+		day, err := time.ParseInLocation(dayLayout, key, now.Location())
+		if err == nil {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	longest, run := 0, 0
+	for i, day := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(day) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+	}
+
+	current := 0
+	day := truncateDay(now)
+	if perDay[day.Format(dayLayout)] == 0 {
+		day = day.AddDate(0, 0, -1)
+	}
+	for perDay[day.Format(dayLayout)] > 0 {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return current, longest
+}
+
+// Plain text report for the terminal
+func (s Summary) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Entries        %d over %d active day(s)\n", s.TotalEntries, s.ActiveDays)
+	fmt.Fprintf(&b, "Per day        %.2f\n", s.AvgPerDay)
+	fmt.Fprintf(&b, "Per week       %.2f\n", s.AvgPerWeek)
+	fmt.Fprintf(&b, "Per month      %.2f\n", s.AvgPerMonth)
+	fmt.Fprintf(&b, "Current streak %d day(s)\n", s.CurrentStreak)
+	fmt.Fprintf(&b, "Longest streak %d day(s)\n", s.LongestStreak)
+	fmt.Fprintf(&b, "Words          %d total, %.1f per entry\n", s.TotalWords, s.AvgWords)
+	fmt.Fprintf(&b, "Entry length   %.1f characters\n", s.AvgEntryLength)
+
+	b.WriteString("\nTop tags\n")
+	if len(s.TopTags) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, tag := range s.TopTags {
+		fmt.Fprintf(&b, "  %-20s %d\n", tag.Tag, tag.Count)
+	}
+
+	b.WriteString("\nTime of day\n")
+	for _, part := range []string{"morning", "afternoon", "evening", "night"} {
+		fmt.Fprintf(&b, "  %-20s %d\n", part, s.TimeOfDay[part])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/apooravm/tjournal/src/api"
+)
+
+// A log at noon on each day, local time
+func logsOn(days ...string) []api.ReadJournalLogRes {
+	logs := make([]api.ReadJournalLogRes, 0, len(days))
+	for _, day := range days {
+		t, err := time.ParseInLocation(dayLayout, day, time.Local)
+		if err != nil {
+			panic(err)
+		}
+		logs = append(logs, api.ReadJournalLogRes{Created_at: t.Add(12 * time.Hour).Format(time.RFC3339)})
+	}
+	return logs
+}
+
+func TestStreaks(t *testing.T) {
+	tests := []struct {
+		name             string
+		now              string
+		days             []string
+		current, longest int
+	}{
+		{"no logs", "2026-01-02", nil, 0, 0},
+		{"today not logged yet", "2026-01-02", []string{"2025-12-31", "2026-01-01"}, 2, 2},
+		{"today logged", "2026-01-02", []string{"2026-01-01", "2026-01-02"}, 2, 2},
+		{"missed yesterday", "2026-01-02", []string{"2025-12-30", "2025-12-31"}, 0, 2},
+		{"gaps", "2026-01-02", []string{"2025-12-28", "2025-12-29", "2025-12-31", "2026-01-02"}, 1, 2},
+		{"month boundary", "2026-03-01", []string{"2026-02-27", "2026-02-28", "2026-03-01"}, 3, 3},
+		{"leap day", "2024-03-01", []string{"2024-02-28", "2024-03-01"}, 1, 1},
+		{"year boundary", "2026-01-01", []string{"2025-12-30", "2025-12-31", "2026-01-01"}, 3, 3},
+		{"several on one day", "2026-01-02", []string{"2026-01-01", "2026-01-02", "2026-01-02", "2026-01-02"}, 2, 2},
+		{"longest in the past", "2026-01-10", []string{"2025-11-01", "2025-11-02", "2025-11-03", "2026-01-09"}, 1, 3},
+	}
+
+	for _, tt := range tests {
+		now, err := time.ParseInLocation(dayLayout, tt.now, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Late in the day, so "today" isn't decided by the hour
+		s := Compute(logsOn(tt.days...), now.Add(20*time.Hour))
+		if s.CurrentStreak != tt.current || s.LongestStreak != tt.longest {
+			t.Errorf("%s: streaks %d, %d, want %d, %d", tt.name, s.CurrentStreak, s.LongestStreak, tt.current, tt.longest)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Set from main, the templates folder next to the config
+var Dir string
+
+// A named skeleton for new logs. On disk it's a text file, <Dir>/<name>.txt:
+//
+//	title: Standup {{date}}
+//	tags: standup, work
+//	---
+//	Yesterday: {{prompt "What did you do yesterday?"}}
+//
+// Title, tags and body can all use the template funcs.
+type Template struct {
+	Name  string
+	Title string
+	Tags  []string
+	Body  string
+}
+
+// Ready to use ones, a file with the same name replaces them
+var builtins = map[string]string{
+	"standup": `title: Standup {{date}}
+tags: standup
+---
+{{weekday}} standup{{with git_branch}} ({{.}}){{end}}
+
+Yesterday:
+- 
+
+Today:
+- 
+
+Blockers:
+- 
+`,
+	"retro": `title: Retro {{date}}
+tags: retro
+---
+Went well:
+- 
+
+Didn't go well:
+- 
+
+Try next time:
+- 
+`,
+	"incident": `title: Incident: {{prompt "What broke?"}}
+tags: incident
+---
+Started: {{date}} {{time}}
+Impact:
+
+Timeline:
+- {{time}} 
+
+Cause:
+
+Follow ups:
+- 
+`,
+}
+
+// What the template funcs need from outside
+type Vars struct {
+	Now time.Time
+	// Asks the user something, for {{prompt "question"}}
+	Prompt func(question string) (string, error)
+}
+
+// Builtin and user template names, sorted
+func List() []string {
+	names := make(map[string]bool)
+	for name := range builtins {
+		names[name] = true
+	}
+
+	if entries, err := os.ReadDir(Dir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() && filepath.Ext(entry.Name()) == ".txt" {
+				names[strings.TrimSuffix(entry.Name(), ".txt")] = true
+			}
+		}
+	}
+
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Finds a template by name, the user's first
+func Load(name string) (Template, error) {
+	byteArr, err := os.ReadFile(filepath.Join(Dir, name+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		builtin, ok := builtins[name]
+		if !ok {
+			return Template{}, fmt.Errorf("no template %q, there's %s", name, strings.Join(List(), ", "))
+		}
+		byteArr = []byte(builtin)
+	} else if err != nil {
+		return Template{}, err
+	}
+
+	t, err := Parse(string(byteArr))
+	t.Name = name
+	return t, err
+}
+
+// Splits a template, or a filled one coming back from the editor, into its parts.
+// Without a --- line it's all body.
+func Parse(text string) (Template, error) {
+	var t Template
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	// An empty header first, a --- further down is then part of the body
+	body, found := strings.CutPrefix(text, "---\n")
+	header := ""
+	if !found {
+		header, body, found = strings.Cut(text, "\n---\n")
+	}
+	if !found {
+		t.Body = text
+		return t, nil
+	}
+
+	for _, line := range strings.Split(header, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !ok {
+			return t, fmt.Errorf("template header line %q should be 'key: value'", line)
+		}
+
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			t.Title = value
+		case "tags":
+			t.Tags = SplitTags(value)
+		default:
+			return t, fmt.Errorf("unknown template header %q, use title or tags", key)
+		}
+	}
+
+	t.Body = body
+	return t, nil
+}
+
+// "a, b c" -> [a b c]
+func SplitTags(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// Runs the template funcs on every part
+func (t Template) Fill(vars Vars) (Template, error) {
+	funcs := funcMap(vars)
+
+	var err error
+	filled := Template{Name: t.Name}
+	if filled.Title, err = execute("title", t.Title, funcs); err != nil {
+		return filled, err
+	}
+	if filled.Body, err = execute("body", t.Body, funcs); err != nil {
+		return filled, err
+	}
+
+	for _, tag := range t.Tags {
+		tag, err := execute("tags", tag, funcs)
+		if err != nil {
+			return filled, err
+		}
+		if tag != "" {
+			filled.Tags = append(filled.Tags, tag)
+		}
+	}
+
+	return filled, nil
+}
+
+// The filled template as text for the editor, Parse reads it back
+func (t Template) String() string {
+	return fmt.Sprintf("title: %s\ntags: %s\n---\n%s", t.Title, strings.Join(t.Tags, ", "), t.Body)
+}
+
+func execute(name string, text string, funcs template.FuncMap) (string, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func funcMap(vars Vars) template.FuncMap {
+	// The same question asked twice, in the title and body say, only gets asked once
+	answers := make(map[string]string)
+
+	return template.FuncMap{
+		// {{date}} or with a Go layout, {{date "Jan 2"}}
+		"date": func(layout ...string) string {
+			if len(layout) > 0 {
+				return vars.Now.Format(layout[0])
+			}
+			return vars.Now.Format("2006-01-02")
+		},
+		"time": func() string {
+			return vars.Now.Format("15:04")
+		},
+		"weekday": func() string {
+			return vars.Now.Weekday().String()
+		},
+		// Empty outside a git repo
+		"git_branch": func() string {
+			out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+			if err != nil {
+				return ""
+			}
+			return strings.TrimSpace(string(out))
+		},
+		"prompt": func(question string) (string, error) {
+			if answer, ok := answers[question]; ok {
+				return answer, nil
+			}
+			if vars.Prompt == nil {
+				return "", fmt.Errorf("template asks %q but there's no one to ask", question)
+			}
+			answer, err := vars.Prompt(question)
+			if err != nil {
+				return "", err
+			}
+			answers[question] = answer
+			return answer, nil
+		},
+	}
+}
@@ -0,0 +1,122 @@
+package templates
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		want  Template
+		error string
+	}{
+		{
+			name: "header and body",
+			text: "title: Standup {{date}}\ntags: standup, work\n---\nYesterday:\n",
+			want: Template{Title: "Standup {{date}}", Tags: []string{"standup", "work"}, Body: "Yesterday:\n"},
+		},
+		{
+			name: "no title header",
+			text: "tags: quick\n---\nbody",
+			want: Template{Tags: []string{"quick"}, Body: "body"},
+		},
+		{
+			name: "empty header",
+			text: "---\nbody\n---\nmore",
+			want: Template{Body: "body\n---\nmore"},
+		},
+		{
+			name: "no header at all",
+			text: "just a body\nover two lines",
+			want: Template{Body: "just a body\nover two lines"},
+		},
+		{
+			name: "crlf",
+			text: "Title: Retro\r\nTAGS: retro\r\n---\r\nWent well:\r\n",
+			want: Template{Title: "Retro", Tags: []string{"retro"}, Body: "Went well:\n"},
+		},
+		{
+			name:  "unknown header",
+			text:  "mood: good\n---\nbody",
+			error: `unknown template header "mood", use title or tags`,
+		},
+		{
+			name:  "header without a colon",
+			text:  "title Standup\n---\nbody",
+			error: `template header line "title Standup" should be 'key: value'`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.text)
+		if tt.error != "" {
+			if err == nil || err.Error() != tt.error {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.error)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.Title != tt.want.Title || !slices.Equal(got.Tags, tt.want.Tags) || got.Body != tt.want.Body {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// What String writes for the editor reads back the same
+func TestStringParsesBack(t *testing.T) {
+	want := Template{Title: "Standup", Tags: []string{"standup", "work"}, Body: "Yesterday:\n---\nnot a header\n"}
+	got, err := Parse(want.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != want.Title || !slices.Equal(got.Tags, want.Tags) || got.Body != want.Body {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFill(t *testing.T) {
+	tmpl, err := Parse(`title: {{prompt "What broke?"}} {{date "Jan 2"}}
+tags: incident, {{weekday}}, {{print}}
+---
+{{prompt "What broke?"}} at {{time}} on {{date}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var asked []string
+	filled, err := tmpl.Fill(Vars{
+		Now: time.Date(2026, time.March, 4, 9, 5, 0, 0, time.UTC),
+		Prompt: func(question string) (string, error) {
+			asked = append(asked, question)
+			return "the db", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filled.Title != "the db Mar 4" {
+		t.Errorf("title %q", filled.Title)
+	}
+	// The tag that came out empty is dropped
+	if !slices.Equal(filled.Tags, []string{"incident", "Wednesday"}) {
+		t.Errorf("tags %q", filled.Tags)
+	}
+	if filled.Body != "the db at 09:05 on 2026-03-04" {
+		t.Errorf("body %q", filled.Body)
+	}
+	if len(asked) != 1 {
+		t.Errorf("asked %q, the same question should only be asked once", asked)
+	}
+
+	if _, err := tmpl.Fill(Vars{Now: time.Now()}); err == nil || !strings.Contains(err.Error(), "no one to ask") {
+		t.Errorf("Fill without a prompt: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package theme
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Colours are hex strings, or ANSI numbers like "5". Empty fields in a custom theme fall back to its base.
+type Theme struct {
+	Name string `json:"-"`
+	// Builtin theme a custom theme starts from, defaults to dark
+	Base string `json:"base,omitempty"`
+
+	Highlight string `json:"highlight,omitempty"` // Tabs, borders, headings
+	Accent    string `json:"accent,omitempty"`    // Cursor, focused panel, input text
+	Text      string `json:"text,omitempty"`      // Text drawn on top of Highlight
+	Muted     string `json:"muted,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Warning   string `json:"warning,omitempty"`
+	Success   string `json:"success,omitempty"`
+	Info      string `json:"info,omitempty"`
+	// Heatmap shades from no entries to a busy day
+	Heat []string `json:"heat,omitempty"`
+}
+
+var builtins = map[string]Theme{
+	"dark": {
+		Highlight: "#7D56F4",
+		Accent:    "#FF75B7",
+		Text:      "#FFFFFF",
+		Muted:     "#777777",
+		Error:     "#FF5F5F",
+		Warning:   "#FFD75F",
+		Success:   "#5FD75F",
+		Info:      "#5FD7FF",
+		Heat:      []string{"#777777", "#0E4429", "#006D32", "#26A641", "#39D353"},
+	},
+	"light": {
+		Highlight: "#874BFD",
+		Accent:    "#D7005F",
+		Text:      "#FFFFFF",
+		Muted:     "#A49FA5",
+		Error:     "#D70000",
+		Warning:   "#AF8700",
+		Success:   "#008700",
+		Info:      "#0087AF",
+		Heat:      []string{"#D0D7DE", "#9BE9A8", "#40C463", "#30A14E", "#216E39"},
+	},
+	"high-contrast": {
+		Highlight: "#FFFF00",
+		Accent:    "#00FFFF",
+		Text:      "#000000",
+		Muted:     "#C0C0C0",
+		Error:     "#FF0000",
+		Warning:   "#FFFF00",
+		Success:   "#00FF00",
+		Info:      "#00FFFF",
+		Heat:      []string{"#C0C0C0", "#005F00", "#008700", "#00D700", "#00FF00"},
+	},
+	"solarized": {
+		Highlight: "#268BD2",
+		Accent:    "#D33682",
+		Text:      "#FDF6E3",
+		Muted:     "#839496",
+		Error:     "#DC322F",
+		Warning:   "#B58900",
+		Success:   "#859900",
+		Info:      "#2AA198",
+		Heat:      []string{"#586E75", "#3F4F00", "#5F7300", "#859900", "#A6BF00"},
+	},
+}
+
+// Unset until the config picks one, so the terminal is only asked about its background when needed
+var current *Theme
+
+// Dark or light depending on the terminal background
+func Default() Theme {
+	if lipgloss.HasDarkBackground() {
+		return named("dark")
+	}
+	return named("light")
+}
+
+func named(name string) Theme {
+	t := builtins[name]
+	t.Name = name
+	t.Heat = append([]string(nil), t.Heat...)
+	return t
+}
+
+// Names of the builtin themes, sorted
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Finds a theme by name among the custom themes from the config and the builtins.
+// Custom themes win over builtins of the same name. An empty name picks Default().
+func Resolve(name string, custom map[string]Theme) (Theme, error) {
+	if name == "" {
+		return Default(), nil
+	}
+
+	if t, ok := custom[name]; ok {
+		baseName := t.Base
+		if baseName == "" {
+			baseName = "dark"
+		}
+		if _, ok := builtins[baseName]; !ok {
+			return Theme{}, fmt.Errorf("theme %q: unknown base theme %q", name, baseName)
+		}
+
+		t.Name = name
+		return t.fill(named(baseName)), nil
+	}
+
+	if _, ok := builtins[name]; ok {
+		return named(name), nil
+	}
+
+	return Theme{}, fmt.Errorf("unknown theme %q", name)
+}
+
+func (t Theme) fill(base Theme) Theme {
+	pick := func(c, fallback string) string {
+		if c == "" {
+			return fallback
+		}
+		return c
+	}
+
+	t.Highlight = pick(t.Highlight, base.Highlight)
+	t.Accent = pick(t.Accent, base.Accent)
+	t.Text = pick(t.Text, base.Text)
+	t.Muted = pick(t.Muted, base.Muted)
+	t.Error = pick(t.Error, base.Error)
+	t.Warning = pick(t.Warning, base.Warning)
+	t.Success = pick(t.Success, base.Success)
+	t.Info = pick(t.Info, base.Info)
+	if len(t.Heat) == 0 {
+		t.Heat = base.Heat
+	}
+	return t
+}
+
+func Set(t Theme) {
+	current = &t
+}
+
+func Current() Theme {
+	if current == nil {
+		Set(Default())
+	}
+	return *current
+}
+
+// https://no-color.org
+func NoColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// Colour for the names the CLI has always used ("red", "green" ...) in the current theme
+func (t Theme) Named(colour string) lipgloss.Color {
+	switch colour {
+	case "red":
+		return lipgloss.Color(t.Error)
+	case "yellow":
+		return lipgloss.Color(t.Warning)
+	case "green":
+		return lipgloss.Color(t.Success)
+	case "cyan", "blue":
+		return lipgloss.Color(t.Info)
+	case "magenta":
+		return lipgloss.Color(t.Accent)
+	default:
+		return lipgloss.Color("")
+	}
+}
@@ -1,10 +1,18 @@
 package ui
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	api "github.com/apooravm/tjournal/src/api"
+	"github.com/apooravm/tjournal/src/bubble_init"
+	"github.com/apooravm/tjournal/src/cache"
 
+	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -12,22 +20,39 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-func GetData() tea.Msg {
-	// status, err := api.CheckServerStatus(PingUrl)
-	// if err != nil {
-	// 	return api.JournError{Code: 400, Message: "Error connecting to the server" + err.Error()}
-	// }
+// Fired every refreshInterval when auto refresh is on
+type refreshTickMsg time.Time
 
-	logs, err := JournalManage.ReadJournalLogs()
-	if err != nil {
-		serverErr, ok := err.(api.ServerErrorRes)
-		if !ok {
-			return api.JournError{Code: 400, Message: err.Error(), Simple: "Error connecting to the server"}
+func refreshTick(interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(t time.Time) tea.Msg {
+		return refreshTickMsg(t)
+	})
+}
+
+// One page of logs from the server. offset 0 replaces what's loaded, anything else is appended.
+type logPageMsg struct {
+	logs   *[]api.ReadJournalLogRes
+	limit  int
+	offset int
+}
+
+// A failed fetch, the error is an *api.Error
+type errMsg struct {
+	err error
+}
+
+// Start fetching more logs when the cursor is this close to the end of the list
+const loadAhead = 5
+
+func GetData(ctx context.Context, limit int, offset int) tea.Cmd {
+	return func() tea.Msg {
+		logs, err := fetchPage(ctx, limit, offset)
+		if err != nil {
+			return errMsg{err}
 		}
-		return api.JournError{Code: 400, Message: "Error connecting to the server | " + serverErr.Message}
-	}
 
-	return logs
+		return logPageMsg{logs: logs, limit: limit, offset: offset}
+	}
 }
 
 func tabBorderWithBottom(left, middle, right string) lipgloss.Border {
@@ -38,34 +63,66 @@ func tabBorderWithBottom(left, middle, right string) lipgloss.Border {
 	return border
 }
 
-var (
-	inactiveTabBorder = tabBorderWithBottom("┴", "─", "┴")
-	activeTabBorder   = tabBorderWithBottom("┘", " ", "└")
-	docStyleTabs      = lipgloss.NewStyle().Padding(1, 2, 1, 2)
-	highlightColor    = lipgloss.AdaptiveColor{Light: "#874BFD", Dark: "#7D56F4"}
-	inactiveTabStyle  = lipgloss.NewStyle().Border(inactiveTabBorder, true).BorderForeground(highlightColor).Padding(0, 1)
-	activeTabStyle    = inactiveTabStyle.Copy().Border(activeTabBorder, true)
-	windowStyle       = lipgloss.NewStyle().BorderForeground(highlightColor).Padding(2, 0).Align(lipgloss.Center).Border(lipgloss.NormalBorder()).UnsetBorderTop()
+const (
+	tabRowHeight = 3
+	helpHeight   = 1
+)
+
+const (
+	readTab = iota
+	createTab
+	calendarTab
+	statsTab
 )
 
 type model struct {
 	statusCode int
+	title      string
 	logs       *[]api.ReadJournalLogRes
 	list       list.Model
 
-	filterAgainst string
+	filterScope filterScope
+	tagPanel    tagPanel
+	calendar    calendarView
+	// Day picked in the calendar, "" shows every day
+	dayFilter string
+	search    searchBar
+	// Older logs for search, tags, the calendar and stats. The list only loads what's scrolled to.
+	cache *cache.Cache
+	// Room for the list before the search box takes its share
+	listHeight int
 
 	inputStyle lipgloss.Style
 
 	quitting bool
 	err      error
+	// A GetData is in flight, so refreshes don't pile up
+	loading         bool
+	refreshInterval time.Duration
+	pageSize        int
+	// Fetched before the TUI started, shown instead of fetching the first page again
+	firstPage *[]api.ReadJournalLogRes
+	// The last page was full, so the server probably has older logs
+	hasMore bool
 
 	tabs         []string
 	tabContent   []string
 	activeTabIdx int
+
+	keys bubble_init.Keymap
+	help help.Model
+
+	// Cancelled on quit so in-flight requests don't hold the app open
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	width  int
+	height int
 }
 
-func InitialModel() model {
+func InitialModel(ctx context.Context, opts Options) model {
+	keys := opts.Keys
+
 	var items []list.Item
 
 	del := list.NewDefaultDelegate()
@@ -73,70 +130,254 @@ func InitialModel() model {
 	del.SetHeight(5)
 	// del.SetSpacing(1)
 
+	del.Styles.SelectedTitle = del.Styles.SelectedTitle.Foreground(accentColor).BorderForeground(accentColor)
+	del.Styles.SelectedDesc = del.Styles.SelectedDesc.Foreground(accentColor).BorderForeground(accentColor)
+
 	logList := list.New(items, del, 0, 0)
+	logList.Styles.Title = logList.Styles.Title.Background(highlightColor).Foreground(textColor)
 
-	m := model{list: logList}
-	m.list.Title = "Journal Logs"
+	m := model{list: logList, keys: keys, help: help.New(), refreshInterval: opts.RefreshInterval, pageSize: opts.PageSize, loading: true}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	if m.pageSize <= 0 {
+		m.pageSize = DefaultPageSize
+	}
+	m.title = "Journal Logs"
+	if opts.Offline {
+		m.title = "Journal Logs (offline)"
+	}
+	m.list.Title = m.title
 	m.list.SetSpinner(spinner.Line)
 
-	// m.list.SetShowHelp(false)
-	m.filterAgainst = "title"
-
-	m.tabs = []string{"Read Logs", "Create Log"}
-	m.tabContent = []string{"", ""}
-	m.activeTabIdx = 0
-
-	m.inputStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF75B7"))
+	// The list's own help is replaced by m.help, which knows about every tab
+	m.list.SetShowHelp(false)
+	m.list.KeyMap.CursorUp = keys.Up
+	m.list.KeyMap.CursorDown = keys.Down
+	m.list.KeyMap.Quit = keys.Quit
+	m.list.KeyMap.ForceQuit = keys.ForceQuit
+	m.list.KeyMap.NextPage = keys.NextPage
+	m.list.KeyMap.PrevPage = keys.PrevPage
+	m.list.KeyMap.GoToStart = keys.GoToStart
+	m.list.KeyMap.GoToEnd = keys.GoToEnd
+	m.list.KeyMap.Filter = keys.Filter
+	m.list.KeyMap.ClearFilter = keys.ClearFilter
+	m.list.KeyMap.AcceptWhileFiltering = keys.FilterAccept
+	m.list.KeyMap.CancelWhileFiltering = keys.FilterCancel
+	m.list.KeyMap.ShowFullHelp.SetEnabled(false)
+	m.list.KeyMap.CloseFullHelp.SetEnabled(false)
+
+	m.setFilterScope(scopeTitle)
+	m.tagPanel = newTagPanel()
+	m.calendar = newCalendarView()
+	m.search = newSearchBar()
+	m.cache = opts.Cache
+	m.firstPage = opts.FirstPage
+
+	m.tabs = []string{"Read Logs", "Create Log", "Calendar", "Stats"}
+	m.tabContent = []string{"", "", "", ""}
+	m.activeTabIdx = readTab
+
+	m.inputStyle = lipgloss.NewStyle().Foreground(accentColor)
+	m.search.input.PromptStyle = m.inputStyle
 	return m
 }
 
 func (m model) Init() tea.Cmd {
-	m.tabContent[0] = m.JournalLogReadView()
-	return tea.Batch(GetData, func() tea.Msg {
+	m.tabContent[readTab] = m.JournalLogReadView()
+	m.tabContent[calendarTab] = m.CalendarView()
+	m.tabContent[statsTab] = m.StatsView()
+	load := GetData(m.ctx, m.pageSize, 0)
+	if m.firstPage != nil {
+		load = func() tea.Msg { return logPageMsg{logs: m.firstPage, limit: m.pageSize, offset: 0} }
+	}
+	cmds := []tea.Cmd{load, func() tea.Msg {
 		var msg JournMessage = "startspinner"
 		return msg
-	})
+	}}
+	if m.refreshInterval > 0 {
+		cmds = append(cmds, refreshTick(m.refreshInterval))
+	}
+	return tea.Batch(cmds...)
+}
+
+// Clear any error and fetch the logs again with the spinner going.
+// Reloads as many logs as are already loaded so the list doesn't shrink back to one page.
+func (m model) refresh() (model, tea.Cmd) {
+	if m.loading {
+		return m, nil
+	}
+
+	limit := m.pageSize
+	if m.logs != nil {
+		limit = max(limit, len(*m.logs))
+	}
+
+	m.loading = true
+	m.err = nil
+	m.refreshTabs()
+	return m, tea.Batch(GetData(m.ctx, limit, 0), m.list.StartSpinner())
+}
+
+// Fetch the next page once the cursor gets near the end of what's loaded
+func (m model) loadMore() (model, tea.Cmd) {
+	if m.loading || !m.hasMore || m.logs == nil {
+		return m, nil
+	}
+
+	if m.list.Index() < len(m.list.Items())-loadAhead {
+		return m, nil
+	}
+
+	m.loading = true
+	return m, tea.Batch(GetData(m.ctx, m.pageSize, len(*m.logs)), m.list.StartSpinner())
+}
+
+func (m model) refreshTabs() {
+	m.tabContent[readTab] = m.JournalLogReadView()
+	m.tabContent[calendarTab] = m.CalendarView()
+	m.tabContent[statsTab] = m.StatsView()
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
+		// Let the filter input have every key while the user is typing
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+
+		if key.Matches(msg, m.keys.ForceQuit) {
+			m.cancel()
+			return m, tea.Quit
+		}
+
+		if m.search.input.Focused() {
+			return m.updateSearchInput(msg)
+		}
+
+		if m.tagPanel.focused {
+			return m.updateTagPanel(msg)
+		}
+
+		if m.activeTabIdx == calendarTab {
+			if updated, cmd, handled := m.updateCalendar(msg); handled {
+				return updated, cmd
+			}
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			m.cancel()
 			return m, tea.Quit
 
-		case "right", "l", "n", "tab":
+		case key.Matches(msg, m.keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+			return m, nil
+
+		case key.Matches(msg, m.keys.Refresh):
+			return m.refresh()
+
+		case key.Matches(msg, m.keys.Tags):
+			if m.activeTabIdx == readTab {
+				m.tagPanel.focused = true
+				m.tabContent[readTab] = m.JournalLogReadView()
+				return m, nil
+			}
+
+		case key.Matches(msg, m.keys.Search):
+			if m.activeTabIdx == readTab {
+				return m.startSearch()
+			}
+
+		case key.Matches(msg, m.keys.SearchExit):
+			// esc clears a fuzzy filter first, same as without search
+			if m.search.active && m.activeTabIdx == readTab && m.list.FilterState() == list.Unfiltered {
+				return m.endSearch()
+			}
+
+		case key.Matches(msg, m.keys.FilterScope):
+			if m.activeTabIdx == readTab {
+				cmd := m.setFilterScope(m.filterScope.next())
+				m.tabContent[readTab] = m.JournalLogReadView()
+				return m, cmd
+			}
+
+		case key.Matches(msg, m.keys.NextTab):
 			m.activeTabIdx = min(m.activeTabIdx+1, len(m.tabs)-1)
 			return m, nil
-		case "left", "h", "p", "shift+tab":
+		case key.Matches(msg, m.keys.PrevTab):
 			m.activeTabIdx = max(m.activeTabIdx-1, 0)
 			return m, nil
 		}
 
-	case api.JournError:
-		m.err = msg
+	case errMsg:
+		m.err = msg.err
+		m.loading = false
+		m.list.StopSpinner()
+		m.refreshTabs()
 		return m, nil
 
-	case *[]api.ReadJournalLogRes:
+	case refreshTickMsg:
+		next := refreshTick(m.refreshInterval)
+		if m.err != nil {
+			// Leave errors on screen until the user retries
+			return m, next
+		}
+		var cmd tea.Cmd
+		m, cmd = m.refresh()
+		return m, tea.Batch(cmd, next)
+
+	case logPageMsg:
 		m.statusCode = 200
-		m.logs = msg
+		if msg.offset == 0 || m.logs == nil {
+			m.logs = msg.logs
+		} else {
+			// Logs written since the last page shift the offsets, so skip ones already loaded
+			seen := make(map[int]bool)
+			for _, log := range *m.logs {
+				seen[log.Log_Id] = true
+			}
+
+			merged := append([]api.ReadJournalLogRes{}, *m.logs...)
+			for _, log := range *msg.logs {
+				if !seen[log.Log_Id] {
+					merged = append(merged, log)
+				}
+			}
+			m.logs = &merged
+		}
+		// A server without paging sends everything at once, which is more than asked for
+		m.hasMore = len(*msg.logs) == msg.limit
+		m.loading = false
+		m.err = nil
 		m.list.StopSpinner()
-
-		newKeyBindings := []key.Binding{key.NewBinding(key.WithKeys("N"), key.WithHelp("N", "New log"))}
-
-		m.list.AdditionalShortHelpKeys = func() []key.Binding {
-			return newKeyBindings
+		all := m.allLogs()
+		m.tagPanel.setLogs(all)
+		m.tagPanel.partial = m.hasMore
+		m.calendar.setLogs(all)
+		m.search.index = nil
+		if m.search.active {
+			m.runSearch()
 		}
+		m.tabContent[calendarTab] = m.CalendarView()
+		m.tabContent[statsTab] = m.StatsView()
 
-		return m, m.list.SetItems(*getItemList(m.logs))
+		cmd := m.list.SetItems(*getItemList(m.visibleLogs()))
+		m.tabContent[readTab] = m.JournalLogReadView()
+		return m, cmd
 		// return m, m.list.StartSpinner()
 
 	case tea.WindowSizeMsg:
-		h, v := docStyle.GetFrameSize()
-		m.list.SetSize(msg.Width-h, msg.Height-v)
+		m.width, m.height = msg.Width, msg.Height
+
+		// Room left inside the tab window once the tab row and help line are drawn
+		h := docStyleTabs.GetHorizontalFrameSize() + windowStyle.GetHorizontalFrameSize() + docStyle.GetHorizontalFrameSize()
+		v := docStyleTabs.GetVerticalFrameSize() + windowStyle.GetVerticalFrameSize() + docStyle.GetVerticalFrameSize() + tabRowHeight + helpHeight
+		m.listHeight = msg.Height - v
+		m.list.SetSize(msg.Width-h-tagPanelStyle.GetHorizontalFrameSize()-tagPanelWidth, m.listHeight)
+		m.sizeList()
 
 		// Helper display
-		// m.help.Width = msg.Width
+		m.help.Width = msg.Width - docStyleTabs.GetHorizontalFrameSize()
 
 	case JournMessage:
 		if msg == "startspinner" {
@@ -144,24 +385,209 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	m.tabContent[0] = m.JournalLogReadView()
-	var listCmd tea.Cmd
+	var listCmd, moreCmd, inputCmd tea.Cmd
 	m.list, listCmd = m.list.Update(msg)
-	return m, listCmd
+	m, moreCmd = m.loadMore()
+	// Only the cursor blink gets this far, keys went to updateSearchInput
+	m.search.input, inputCmd = m.search.input.Update(msg)
+	m.tabContent[readTab] = m.JournalLogReadView()
+	return m, tea.Batch(listCmd, moreCmd, inputCmd)
 }
 
-func (m model) JournalLogReadView() string {
-	question := lipgloss.NewStyle().Width(50).Align(lipgloss.Center).Render("Are you sure you want to eat marmalade?")
+// Switch which fields the fuzzy filter matches against and show it in the status bar.
+// A filter that's already typed in is run again against the new fields.
+func (m *model) setFilterScope(scope filterScope) tea.Cmd {
+	m.filterScope = scope
+	m.list.Filter = scopedFilter(scope)
+	m.list.SetStatusBarItemName("log · filter: "+scope.String(), "logs · filter: "+scope.String())
+
+	if m.list.FilterState() == list.Unfiltered {
+		return nil
+	}
+	// Setting the items refilters them, this version of the list has no SetFilterText
+	return m.list.SetItems(m.list.Items())
+}
+
+// Keys while the tag panel has focus. Nothing is forwarded to the list.
+func (m model) updateTagPanel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		m.tagPanel.moveCursor(-1)
+	case key.Matches(msg, m.keys.Down):
+		m.tagPanel.moveCursor(1)
+	case key.Matches(msg, m.keys.TagToggle):
+		m.tagPanel.toggleCurrent()
+	case key.Matches(msg, m.keys.TagMode):
+		m.tagPanel.matchAll = !m.tagPanel.matchAll
+	case key.Matches(msg, m.keys.TagClear):
+		m.tagPanel.clear()
+	case key.Matches(msg, m.keys.TagBack):
+		m.tagPanel.focused = false
+	}
+
+	cmd := m.list.SetItems(*getItemList(m.visibleLogs()))
+	m.tabContent[readTab] = m.JournalLogReadView()
+	return m, cmd
+}
+
+// Keys on the calendar tab. Returns false for keys it leaves to the rest of Update, like tab switching.
+func (m model) updateCalendar(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, m.keys.Left):
+		m.calendar.moveDays(-1)
+	case key.Matches(msg, m.keys.Right):
+		m.calendar.moveDays(1)
+	case key.Matches(msg, m.keys.Up):
+		m.calendar.moveDays(-7)
+	case key.Matches(msg, m.keys.Down):
+		m.calendar.moveDays(7)
+	case key.Matches(msg, m.keys.PrevMonth):
+		m.calendar.moveMonths(-1)
+	case key.Matches(msg, m.keys.NextMonth):
+		m.calendar.moveMonths(1)
+
+	case key.Matches(msg, m.keys.ShowDay):
+		m.setDayFilter(dayKey(m.calendar.cursor))
+		cmd = m.list.SetItems(*getItemList(m.visibleLogs()))
+		m.activeTabIdx = readTab
+		m.tabContent[readTab] = m.JournalLogReadView()
+
+	case key.Matches(msg, m.keys.ClearDay):
+		m.setDayFilter("")
+		cmd = m.list.SetItems(*getItemList(m.visibleLogs()))
+		m.tabContent[readTab] = m.JournalLogReadView()
+
+	default:
+		return m, nil, false
+	}
+
+	m.tabContent[calendarTab] = m.CalendarView()
+	return m, cmd, true
+}
+
+func (m *model) setDayFilter(day string) {
+	m.dayFilter = day
+	if day == "" {
+		m.list.Title = m.title
+	} else {
+		m.list.Title = m.title + " · " + day
+	}
+}
+
+// The loaded logs plus older ones from the cache that haven't been scrolled to yet, newest first.
+// nil until the first page is in.
+func (m model) allLogs() *[]api.ReadJournalLogRes {
+	if m.logs == nil {
+		return nil
+	}
+
+	logs := append([]api.ReadJournalLogRes{}, *m.logs...)
+	if m.cache == nil {
+		return &logs
+	}
+
+	seen := make(map[int]bool)
+	for _, log := range logs {
+		seen[log.Log_Id] = true
+	}
+	for _, log := range m.cache.Logs {
+		if !seen[log.Log_Id] {
+			logs = append(logs, log)
+		}
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Created_at > logs[j].Created_at
+	})
+	return &logs
+}
+
+// Said under views built from allLogs while older logs are still on the server
+func (m model) partialNote() string {
+	return fmt.Sprintf("Based on the %d logs loaded or cached so far. Scroll the log list to load older ones.", len(*m.allLogs()))
+}
+
+// Logs that pass the tag selection and calendar day, in server order or by search rank.
+// With a tag or day picked, cached logs that aren't loaded yet show up too.
+func (m model) visibleLogs() *[]api.ReadJournalLogRes {
+	visible := []api.ReadJournalLogRes{}
+	source := m.logs
+	switch {
+	case m.search.results != nil:
+		source = m.search.results
+	case m.tagPanel.active() || m.dayFilter != "":
+		source = m.allLogs()
+	}
+	if source == nil {
+		return &visible
+	}
+
+	for _, log := range *source {
+		if !m.tagPanel.matches(log.Tags) {
+			continue
+		}
+
+		if m.dayFilter != "" {
+			created, ok := log.CreatedTime()
+			if !ok || dayKey(created) != m.dayFilter {
+				continue
+			}
+		}
+
+		visible = append(visible, log)
+	}
+	return &visible
+}
 
+// The error with a way out, instead of a dead end
+func (m model) errorView() string {
+	hint := fmt.Sprintf("%s retry • %s quit", m.keys.Refresh.Help().Key, m.keys.Quit.Help().Key)
+	if errors.Is(m.err, api.ErrUnauthorized) {
+		// Retrying won't help with a stale token, a restart asks for the password
+		hint = fmt.Sprintf("Session expired, restart tjournal to log in again • %s quit", m.keys.Quit.Help().Key)
+	}
+	return docStyle.Render(lipgloss.JoinVertical(lipgloss.Center, m.err.Error(), "", mutedStyle.Render(hint)))
+}
+
+func (m model) JournalLogReadView() string {
 	if m.err != nil {
-		return m.err.Error()
+		return m.errorView()
 	}
 
 	if m.quitting {
 		return "Bye!\n"
 	}
 
-	return docStyle.Render(lipgloss.JoinHorizontal(lipgloss.Center, m.list.View(), question))
+	view := lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), m.tagPanel.View(m.list.Height()))
+	if m.search.active {
+		view = lipgloss.JoinVertical(lipgloss.Left, m.search.View(), view)
+	}
+	return docStyle.Render(view)
+}
+
+func (m model) CalendarView() string {
+	if m.err != nil {
+		return m.errorView()
+	}
+
+	view := m.calendar.View()
+	if m.hasMore {
+		view = lipgloss.JoinVertical(lipgloss.Left, view, "", mutedStyle.Render(m.partialNote()))
+	}
+	return docStyle.Render(view)
+}
+
+func (m model) StatsView() string {
+	if m.err != nil {
+		return m.errorView()
+	}
+
+	view := renderStats(m.allLogs())
+	if m.hasMore {
+		view = lipgloss.JoinVertical(lipgloss.Left, view, "", mutedStyle.Render(m.partialNote()))
+	}
+	return docStyle.Render(view)
 }
 
 func (m model) View() string {
@@ -169,9 +595,17 @@ func (m model) View() string {
 
 	var renderedTabs []string
 
+	tabsWidth := 0
+	for _, t := range m.tabs {
+		tabsWidth += lipgloss.Width(inactiveTabStyle.Render(t))
+	}
+	windowWidth := max(tabsWidth, m.width-docStyleTabs.GetHorizontalFrameSize())
+	// The window is wider than the tabs, so the border carries on past the last one
+	hasGap := windowWidth > tabsWidth
+
 	for i, t := range m.tabs {
 		var style lipgloss.Style
-		isFirst, isLast, isActive := i == 0, i == len(m.tabs)-1, i == m.activeTabIdx
+		isFirst, isLast, isActive := i == 0, i == len(m.tabs)-1 && !hasGap, i == m.activeTabIdx
 		if isActive {
 			style = activeTabStyle.Copy()
 		} else {
@@ -191,10 +625,17 @@ func (m model) View() string {
 		renderedTabs = append(renderedTabs, style.Render(t))
 	}
 
-	row := lipgloss.JoinHorizontal(lipgloss.Top, renderedTabs...)
+	if hasGap {
+		gap := strings.Repeat("─", windowWidth-tabsWidth-1) + "┐"
+		renderedTabs = append(renderedTabs, lipgloss.NewStyle().Foreground(highlightColor).Render(gap))
+	}
+
+	row := lipgloss.JoinHorizontal(lipgloss.Bottom, renderedTabs...)
 	doc.WriteString(row)
 	doc.WriteString("\n")
-	doc.WriteString(windowStyle.Width((lipgloss.Width(row) - windowStyle.GetHorizontalFrameSize())).Render(m.tabContent[m.activeTabIdx]))
+	doc.WriteString(windowStyle.Width((windowWidth - windowStyle.GetHorizontalFrameSize())).Render(m.tabContent[m.activeTabIdx]))
+	doc.WriteString("\n")
+	doc.WriteString(m.help.View(m.helpKeys()))
 	return docStyleTabs.Render(doc.String())
 
 }
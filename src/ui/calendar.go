@@ -0,0 +1,161 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	api "github.com/apooravm/tjournal/src/api"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const dayKeyLayout = "2006-01-02"
+
+func dayKey(t time.Time) string {
+	return t.Format(dayKeyLayout)
+}
+
+// Month calendar plus a year long heatmap of how many logs were written each day
+type calendarView struct {
+	cursor time.Time
+	counts map[string]int
+}
+
+func newCalendarView() calendarView {
+	return calendarView{cursor: truncateDay(time.Now()), counts: make(map[string]int)}
+}
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func (c *calendarView) setLogs(logs *[]api.ReadJournalLogRes) {
+	c.counts = make(map[string]int)
+	if logs == nil {
+		return
+	}
+
+	for _, log := range *logs {
+		created, ok := log.CreatedTime()
+		if !ok {
+			continue
+		}
+		c.counts[dayKey(created)]++
+	}
+}
+
+func (c *calendarView) moveDays(days int) {
+	c.cursor = c.cursor.AddDate(0, 0, days)
+}
+
+// Jump by whole months, clamping the day so Jan 31 -> Feb 28 instead of overflowing into March
+func (c *calendarView) moveMonths(months int) {
+	y, m, d := c.cursor.Date()
+	first := time.Date(y, m+time.Month(months), 1, 0, 0, 0, 0, c.cursor.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	c.cursor = first.AddDate(0, 0, min(d, last)-1)
+}
+
+func (c calendarView) View() string {
+	return lipgloss.JoinVertical(lipgloss.Left, c.monthView(), "", c.heatmapView())
+}
+
+func (c calendarView) monthView() string {
+	var b strings.Builder
+
+	first := time.Date(c.cursor.Year(), c.cursor.Month(), 1, 0, 0, 0, 0, c.cursor.Location())
+	today := dayKey(time.Now())
+
+	b.WriteString(headerStyle.Render(first.Format("January 2006")))
+	b.WriteString("\n\nMo Tu We Th Fr Sa Su\n")
+
+	// Monday first, time.Weekday is Sunday first
+	offset := (int(first.Weekday()) + 6) % 7
+	b.WriteString(strings.Repeat("   ", offset))
+
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		key := dayKey(day)
+		cell := fmt.Sprintf("%2d", day.Day())
+
+		switch {
+		case key == dayKey(c.cursor):
+			cell = calCursorStyle.Render(cell)
+		case c.counts[key] > 0:
+			cell = calEntryStyle.Render(cell)
+		case key == today:
+			cell = calTodayStyle.Render(cell)
+		}
+
+		b.WriteString(cell)
+		if (offset+day.Day())%7 == 0 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+
+	selected := c.counts[dayKey(c.cursor)]
+	b.WriteString(fmt.Sprintf("\n\n%s: %d log(s)", c.cursor.Format("Mon, 02 Jan 2006"), selected))
+	return b.String()
+}
+
+// Themes can have any number of shades, counts above the last one use the busiest shade
+func heatLevel(count int) lipgloss.Style {
+	thresholds := []int{0, 1, 2, 4}
+
+	level := len(thresholds)
+	for i, limit := range thresholds {
+		if count <= limit {
+			level = i
+			break
+		}
+	}
+	return heatLevels[min(level, len(heatLevels)-1)]
+}
+
+// Contribution style grid of the past year, one column per week and one row per weekday
+func (c calendarView) heatmapView() string {
+	const weeks = 53
+
+	today := truncateDay(time.Now())
+	// Start on the Monday 52 weeks before the current week
+	start := today.AddDate(0, 0, -((int(today.Weekday())+6)%7)-(weeks-1)*7)
+
+	rows := make([]strings.Builder, 7)
+	labels := []string{"Mon ", "    ", "Wed ", "    ", "Fri ", "    ", "    "}
+	for i := range rows {
+		rows[i].WriteString(mutedStyle.Render(labels[i]))
+	}
+
+	for w := 0; w < weeks; w++ {
+		for d := 0; d < 7; d++ {
+			day := start.AddDate(0, 0, w*7+d)
+			if day.After(today) {
+				rows[d].WriteString(" ")
+				continue
+			}
+
+			cell := "■"
+			if dayKey(day) == dayKey(c.cursor) {
+				cell = calCursorStyle.Render(cell)
+			} else {
+				cell = heatLevel(c.counts[dayKey(day)]).Render(cell)
+			}
+			rows[d].WriteString(cell)
+		}
+	}
+
+	lines := []string{headerStyle.Render("Past year")}
+	for i := range rows {
+		lines = append(lines, rows[i].String())
+	}
+
+	legend := "Less "
+	for _, level := range heatLevels {
+		legend += level.Render("■")
+	}
+	lines = append(lines, mutedStyle.Render("    "+legend+" More"))
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apooravm/tjournal/src/theme"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// One question at a time for `tjournal daily`
+type dailyModel struct {
+	questions []string
+	answers   []string
+	idx       int
+	input     textinput.Model
+	// Shown above the questions, eg. that the answers go onto today's entry
+	note      string
+	cancelled bool
+}
+
+func newDailyModel(questions []string, note string) dailyModel {
+	input := textinput.New()
+	input.Placeholder = "Answer, or leave it empty to skip"
+	input.Focus()
+	input.Prompt = "> "
+
+	return dailyModel{
+		questions: questions,
+		answers:   make([]string, len(questions)),
+		input:     input,
+		note:      note,
+	}
+}
+
+func (m dailyModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m dailyModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.input.Width = max(msg.Width-6, 10)
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			m.cancelled = true
+			return m, tea.Quit
+
+		case "enter":
+			m.answers[m.idx] = strings.TrimSpace(m.input.Value())
+			if m.idx == len(m.questions)-1 {
+				return m, tea.Quit
+			}
+			m.idx++
+			m.input.SetValue(m.answers[m.idx])
+			return m, nil
+
+		case "shift+tab", "up":
+			// Back a question, keeping what was typed so far
+			m.answers[m.idx] = strings.TrimSpace(m.input.Value())
+			m.idx = max(m.idx-1, 0)
+			m.input.SetValue(m.answers[m.idx])
+			m.input.CursorEnd()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m dailyModel) View() string {
+	var sections []string
+	if m.note != "" {
+		sections = append(sections, mutedStyle.Render(m.note), "")
+	}
+
+	progress := mutedStyle.Render(fmt.Sprintf("%d/%d", m.idx+1, len(m.questions)))
+	sections = append(sections,
+		headerStyle.Render(m.questions[m.idx])+"  "+progress,
+		m.input.View(),
+		"",
+		mutedStyle.Render("enter next • shift+tab back • esc cancel"),
+	)
+
+	return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, sections...))
+}
+
+// Asks the questions and returns the answers, one per question. ok is false if the user cancelled.
+func RunDaily(ctx context.Context, questions []string, t theme.Theme, note string) (answers []string, ok bool, err error) {
+	if len(questions) == 0 {
+		return nil, false, fmt.Errorf("no daily questions configured")
+	}
+	applyTheme(t)
+
+	final, err := tea.NewProgram(newDailyModel(questions, note), tea.WithContext(ctx)).Run()
+	if err != nil {
+		return nil, false, err
+	}
+
+	m := final.(dailyModel)
+	if m.cancelled {
+		return nil, false, nil
+	}
+	return m.answers, true, nil
+}
@@ -1,22 +1,133 @@
 package ui
 
 import (
+	"strings"
+
 	api "github.com/apooravm/tjournal/src/api"
 	"github.com/charmbracelet/bubbles/list"
 )
 
+// Separates the title, body and tags inside an item's FilterValue
+const filterSep = "\x1f"
+
+type filterScope int
+
+const (
+	scopeTitle filterScope = iota
+	scopeBody
+	scopeTags
+	scopeAll
+)
+
+func (s filterScope) String() string {
+	switch s {
+	case scopeBody:
+		return "body"
+	case scopeTags:
+		return "tags"
+	case scopeAll:
+		return "all"
+	default:
+		return "title"
+	}
+}
+
+func (s filterScope) next() filterScope {
+	return (s + 1) % (scopeAll + 1)
+}
+
 type item struct {
 	title, desc string
+	body        string
+	tags        []string
 }
 
 func (i item) Title() string       { return i.title }
 func (i item) Description() string { return i.desc }
-func (i item) FilterValue() string { return i.desc }
+
+// Carries every searchable field, the active scope picks which ones the filter looks at
+func (i item) FilterValue() string {
+	return strings.Join([]string{i.title, i.body, strings.Join(i.tags, " ")}, filterSep)
+}
 
 func getItemList(logs *[]api.ReadJournalLogRes) *[]list.Item {
 	var items []list.Item
 	for _, log := range *logs {
-		items = append(items, item{title: log.Title, desc: log.Log + timeStrParser(log.Created_at)})
+		items = append(items, item{
+			title: log.Title,
+			desc:  log.Log + timeStrParser(log.Created_at),
+			body:  log.Log,
+			tags:  log.Tags,
+		})
 	}
 	return &items
 }
+
+// Pulls a scope prefix out of the filter term.
+// "#work" searches tags, "t:", "b:" and "a:" search title, body and everything.
+func parseFilterTerm(term string, scope filterScope) (string, filterScope) {
+	switch {
+	case strings.HasPrefix(term, "#"):
+		return term[1:], scopeTags
+	case strings.HasPrefix(term, "t:"):
+		return term[2:], scopeTitle
+	case strings.HasPrefix(term, "b:"):
+		return term[2:], scopeBody
+	case strings.HasPrefix(term, "a:"):
+		return term[2:], scopeAll
+	}
+	return term, scope
+}
+
+// Fuzzy filter that only matches against the fields in the given scope
+func scopedFilter(scope filterScope) list.FilterFunc {
+	return func(term string, targets []string) []list.Rank {
+		term, scope := parseFilterTerm(term, scope)
+
+		// A bare prefix such as "#" should still list everything
+		if term == "" {
+			ranks := make([]list.Rank, len(targets))
+			for i := range targets {
+				ranks[i] = list.Rank{Index: i}
+			}
+			return ranks
+		}
+
+		scoped := make([]string, len(targets))
+		titleLens := make([]int, len(targets))
+		for i, target := range targets {
+			fields := strings.SplitN(target, filterSep, 3)
+			for len(fields) < 3 {
+				fields = append(fields, "")
+			}
+
+			switch scope {
+			case scopeTitle:
+				scoped[i] = fields[0]
+				titleLens[i] = len(fields[0])
+			case scopeBody:
+				scoped[i] = fields[1]
+			case scopeTags:
+				scoped[i] = fields[2]
+			default:
+				scoped[i] = strings.Join(fields, " ")
+				titleLens[i] = len(fields[0])
+			}
+		}
+
+		ranks := list.DefaultFilter(term, scoped)
+
+		// The delegate highlights matches in the title, so drop indexes that landed elsewhere
+		for i, rank := range ranks {
+			var inTitle []int
+			for _, idx := range rank.MatchedIndexes {
+				if idx < titleLens[rank.Index] {
+					inTitle = append(inTitle, idx)
+				}
+			}
+			ranks[i].MatchedIndexes = inTitle
+		}
+
+		return ranks
+	}
+}
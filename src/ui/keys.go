@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// help.KeyMap for whatever part of the UI currently has the keys
+type contextHelp struct {
+	short []key.Binding
+	full  [][]key.Binding
+}
+
+func (c contextHelp) ShortHelp() []key.Binding  { return c.short }
+func (c contextHelp) FullHelp() [][]key.Binding { return c.full }
+
+func (m model) helpKeys() contextHelp {
+	k := m.keys
+
+	switch {
+	case m.err != nil:
+		return contextHelp{
+			short: []key.Binding{k.Refresh, k.Quit},
+			full:  [][]key.Binding{{k.Refresh, k.Quit}},
+		}
+
+	case m.search.input.Focused():
+		return contextHelp{
+			short: []key.Binding{searchDone, k.SearchExit},
+			full:  [][]key.Binding{{searchDone, k.SearchExit}},
+		}
+
+	case m.tagPanel.focused:
+		return contextHelp{
+			short: []key.Binding{k.TagToggle, k.TagMode, k.TagClear, k.TagBack},
+			full: [][]key.Binding{
+				{k.Up, k.Down},
+				{k.TagToggle, k.TagMode, k.TagClear, k.TagBack},
+			},
+		}
+
+	case m.activeTabIdx == readTab:
+		filter := k.Filter
+		short := []key.Binding{k.Up, k.Down, k.Search, filter, k.Tags, k.FilterScope, k.Refresh, k.Help, k.Quit}
+		if m.search.active {
+			short = []key.Binding{k.Up, k.Down, k.Search, k.SearchExit, filter, k.Tags, k.Help, k.Quit}
+		}
+		return contextHelp{
+			short: short,
+			full: [][]key.Binding{
+				{k.Up, k.Down, k.NextPage, k.PrevPage, k.GoToStart, k.GoToEnd},
+				{k.Search, k.SearchExit, filter, k.ClearFilter, k.FilterScope, k.Tags},
+				{k.NextTab, k.PrevTab, k.Refresh},
+				{k.Help, k.Quit},
+			},
+		}
+
+	case m.activeTabIdx == calendarTab:
+		return contextHelp{
+			short: []key.Binding{k.Left, k.Right, k.ShowDay, k.ClearDay, k.Help, k.Quit},
+			full: [][]key.Binding{
+				{k.Left, k.Right, k.Up, k.Down},
+				{k.PrevMonth, k.NextMonth, k.ShowDay, k.ClearDay},
+				{k.NextTab, k.PrevTab, k.Refresh},
+				{k.Help, k.Quit},
+			},
+		}
+
+	default:
+		return contextHelp{
+			short: []key.Binding{k.NextTab, k.PrevTab, k.Help, k.Quit},
+			full:  k.FullHelp(),
+		}
+	}
+}
@@ -1,24 +1,62 @@
 package ui
 
 import (
-	"log"
+	"context"
+	"time"
 
 	api "github.com/apooravm/tjournal/src/api"
+	"github.com/apooravm/tjournal/src/bubble_init"
+	"github.com/apooravm/tjournal/src/cache"
+	"github.com/apooravm/tjournal/src/theme"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 var (
 	JournalManage api.JournalDB
-	docStyle      = lipgloss.NewStyle().Margin(1, 2)
+	// Where GetData gets its pages, the server or the local cache when offline
+	fetchPage func(ctx context.Context, limit int, offset int) (*[]api.ReadJournalLogRes, error)
+	docStyle  = lipgloss.NewStyle().Margin(1, 2)
 )
 
-func InitRun(journManage api.JournalDB) error {
+// Settings from the config that shape the TUI
+type Options struct {
+	Keys  bubble_init.Keymap
+	Theme theme.Theme
+	// Refetch the logs this often, 0 turns auto refresh off
+	RefreshInterval time.Duration
+	// Logs fetched per request, more pages load while scrolling
+	PageSize int
+	// Server unreachable, browse the cached logs instead
+	Offline bool
+	Cache   *cache.Cache
+	// First page of logs, already fetched. It has to be PageSize long, or shorter if that's all there is.
+	FirstPage *[]api.ReadJournalLogRes
+}
+
+const DefaultPageSize = 50
+
+func InitRun(ctx context.Context, journManage api.JournalDB, opts Options) error {
 	JournalManage = journManage
+	applyTheme(opts.Theme)
+
+	if opts.Offline {
+		fetchPage = func(ctx context.Context, limit int, offset int) (*[]api.ReadJournalLogRes, error) {
+			return opts.Cache.Page(limit, offset), nil
+		}
+	} else {
+		fetchPage = func(ctx context.Context, limit int, offset int) (*[]api.ReadJournalLogRes, error) {
+			logs, err := JournalManage.ReadJournalLogsPage(ctx, limit, offset)
+			if err == nil {
+				// Best effort, the cache only matters for the next offline run
+				_ = cache.StorePage(*logs)
+			}
+			return logs, err
+		}
+	}
 
-	p := tea.NewProgram(InitialModel())
+	p := tea.NewProgram(InitialModel(ctx, opts))
 	if _, err := p.Run(); err != nil {
-		log.Fatal(err)
 		return err
 	} else {
 		return nil
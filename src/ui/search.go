@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	api "github.com/apooravm/tjournal/src/api"
+	"github.com/apooravm/tjournal/src/search"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The search box and the line under it
+const searchBarHeight = 2
+
+// Search mode on the read tab. The list shows ranked matches from the index instead of every log,
+// tags and the calendar day still narrow them down.
+type searchBar struct {
+	active bool
+	input  textinput.Model
+	// Built on first search, dropped whenever the logs change
+	index *search.Index
+	// nil until there's a query
+	results *[]api.ReadJournalLogRes
+	err     string
+}
+
+func newSearchBar() searchBar {
+	input := textinput.New()
+	input.Prompt = "search: "
+	input.Placeholder = `words, "a phrase", pre*, OR, -not`
+	return searchBar{input: input}
+}
+
+var searchDone = key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "browse results"))
+
+func (m model) startSearch() (model, tea.Cmd) {
+	m.search.active = true
+	cmd := m.search.input.Focus()
+	m.sizeList()
+	m.tabContent[readTab] = m.JournalLogReadView()
+	return m, cmd
+}
+
+func (m model) endSearch() (model, tea.Cmd) {
+	m.search.active = false
+	m.search.input.Blur()
+	m.search.input.SetValue("")
+	m.search.results = nil
+	m.search.err = ""
+	m.sizeList()
+
+	cmd := m.list.SetItems(*getItemList(m.visibleLogs()))
+	m.tabContent[readTab] = m.JournalLogReadView()
+	return m, cmd
+}
+
+// Keys while the search box has focus. Everything but enter and esc is typing.
+func (m model) updateSearchInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.SearchExit):
+		return m.endSearch()
+	case key.Matches(msg, searchDone):
+		m.search.input.Blur()
+		m.tabContent[readTab] = m.JournalLogReadView()
+		return m, nil
+	}
+
+	before := m.search.input.Value()
+	var inputCmd tea.Cmd
+	m.search.input, inputCmd = m.search.input.Update(msg)
+	if m.search.input.Value() == before {
+		m.tabContent[readTab] = m.JournalLogReadView()
+		return m, inputCmd
+	}
+
+	m.runSearch()
+	m.list.ResetSelected()
+	listCmd := m.list.SetItems(*getItemList(m.visibleLogs()))
+	m.tabContent[readTab] = m.JournalLogReadView()
+	return m, tea.Batch(inputCmd, listCmd)
+}
+
+// Runs what's in the search box. A query that doesn't parse yet, like an open quote mid typing, keeps the last results.
+func (m *model) runSearch() {
+	query := strings.TrimSpace(m.search.input.Value())
+	m.search.err = ""
+	if query == "" {
+		m.search.results = nil
+		return
+	}
+
+	q, err := search.Parse(query)
+	if err != nil {
+		m.search.err = err.Error()
+		return
+	}
+
+	if m.search.index == nil {
+		all := m.allLogs()
+		if all == nil {
+			// Nothing loaded yet, the first page reruns the search
+			return
+		}
+		m.search.index = search.Build(*all)
+	}
+
+	results := m.search.index.Run(q)
+	logs := make([]api.ReadJournalLogRes, len(results))
+	for i, result := range results {
+		logs[i] = result.Log
+	}
+	m.search.results = &logs
+}
+
+// List height minus the search box when it's showing
+func (m *model) sizeList() {
+	height := m.listHeight
+	if m.search.active {
+		height -= searchBarHeight
+	}
+	m.list.SetSize(m.list.Width(), max(height, 0))
+}
+
+func (s searchBar) View() string {
+	status := ""
+	switch {
+	case s.err != "":
+		status = s.err
+	case s.results != nil:
+		status = fmt.Sprintf("%d matches, best first", len(*s.results))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, s.input.View(), mutedStyle.Render(status))
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	api "github.com/apooravm/tjournal/src/api"
+	"github.com/apooravm/tjournal/src/stats"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const statsBarWidth = 30
+
+func statsBar(count, most int) string {
+	if most == 0 {
+		return ""
+	}
+	return statsBarStyle.Render(strings.Repeat("█", count*statsBarWidth/most))
+}
+
+func renderStats(logs *[]api.ReadJournalLogRes) string {
+	if logs == nil {
+		return "Loading..."
+	}
+
+	s := stats.Compute(*logs, time.Now())
+
+	overview := strings.Join([]string{
+		headerStyle.Render("Overview"),
+		fmt.Sprintf("Entries          %d", s.TotalEntries),
+		fmt.Sprintf("Active days      %d", s.ActiveDays),
+		fmt.Sprintf("Per day/wk/mo    %.2f / %.2f / %.2f", s.AvgPerDay, s.AvgPerWeek, s.AvgPerMonth),
+		fmt.Sprintf("Current streak   %d day(s)", s.CurrentStreak),
+		fmt.Sprintf("Longest streak   %d day(s)", s.LongestStreak),
+		fmt.Sprintf("Total words      %d", s.TotalWords),
+		fmt.Sprintf("Avg words        %.1f", s.AvgWords),
+		fmt.Sprintf("Avg length       %.1f chars", s.AvgEntryLength),
+	}, "\n")
+
+	tagLines := []string{headerStyle.Render("Top tags")}
+	most := 0
+	if len(s.TopTags) > 0 {
+		most = s.TopTags[0].Count
+	}
+	for _, tag := range s.TopTags {
+		tagLines = append(tagLines, fmt.Sprintf("%-14s %3d %s", tag.Tag, tag.Count, statsBar(tag.Count, most)))
+	}
+	if len(s.TopTags) == 0 {
+		tagLines = append(tagLines, mutedStyle.Render("No tags yet"))
+	}
+
+	parts := []string{"morning", "afternoon", "evening", "night"}
+	most = 0
+	for _, part := range parts {
+		most = max(most, s.TimeOfDay[part])
+	}
+	dayLines := []string{headerStyle.Render("Time of day")}
+	for _, part := range parts {
+		dayLines = append(dayLines, fmt.Sprintf("%-14s %3d %s", part, s.TimeOfDay[part], statsBar(s.TimeOfDay[part], most)))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		overview, "",
+		strings.Join(tagLines, "\n"), "",
+		strings.Join(dayLines, "\n"),
+	)
+}
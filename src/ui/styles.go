@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"github.com/apooravm/tjournal/src/theme"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	inactiveTabBorder = tabBorderWithBottom("┴", "─", "┴")
+	activeTabBorder   = tabBorderWithBottom("┘", " ", "└")
+	docStyleTabs      = lipgloss.NewStyle().Padding(1, 2, 1, 2)
+
+	highlightColor lipgloss.Color
+	accentColor    lipgloss.Color
+	textColor      lipgloss.Color
+
+	inactiveTabStyle lipgloss.Style
+	activeTabStyle   lipgloss.Style
+	windowStyle      lipgloss.Style
+	headerStyle      lipgloss.Style
+	mutedStyle       lipgloss.Style
+
+	tagPanelStyle    lipgloss.Style
+	tagPanelFocused  lipgloss.Style
+	tagCursorStyle   lipgloss.Style
+	tagSelectedStyle lipgloss.Style
+
+	calEntryStyle  lipgloss.Style
+	calCursorStyle lipgloss.Style
+	calTodayStyle  lipgloss.Style
+	// Heatmap shades from no entries up to a busy day
+	heatLevels []lipgloss.Style
+
+	statsBarStyle lipgloss.Style
+)
+
+// Rebuild every style from the theme. Has to run before the model is created.
+func applyTheme(t theme.Theme) {
+	highlightColor = lipgloss.Color(t.Highlight)
+	accentColor = lipgloss.Color(t.Accent)
+	textColor = lipgloss.Color(t.Text)
+	muted := lipgloss.Color(t.Muted)
+
+	inactiveTabStyle = lipgloss.NewStyle().Border(inactiveTabBorder, true).BorderForeground(highlightColor).Padding(0, 1)
+	activeTabStyle = inactiveTabStyle.Copy().Border(activeTabBorder, true)
+	windowStyle = lipgloss.NewStyle().BorderForeground(highlightColor).Padding(2, 0).Align(lipgloss.Center).Border(lipgloss.NormalBorder()).UnsetBorderTop()
+	headerStyle = lipgloss.NewStyle().Bold(true).Foreground(highlightColor)
+	mutedStyle = lipgloss.NewStyle().Foreground(muted)
+
+	tagPanelStyle = lipgloss.NewStyle().Width(tagPanelWidth).Padding(0, 1).Border(lipgloss.RoundedBorder()).BorderForeground(highlightColor)
+	tagPanelFocused = tagPanelStyle.Copy().BorderForeground(accentColor)
+	tagCursorStyle = lipgloss.NewStyle().Foreground(accentColor).Bold(true)
+	tagSelectedStyle = lipgloss.NewStyle().Foreground(highlightColor).Bold(true)
+
+	calEntryStyle = lipgloss.NewStyle().Foreground(textColor).Background(highlightColor)
+	calCursorStyle = lipgloss.NewStyle().Foreground(accentColor).Reverse(true)
+	calTodayStyle = lipgloss.NewStyle().Underline(true)
+
+	heatLevels = []lipgloss.Style{}
+	for _, shade := range t.Heat {
+		heatLevels = append(heatLevels, lipgloss.NewStyle().Foreground(lipgloss.Color(shade)))
+	}
+	if len(heatLevels) == 0 {
+		heatLevels = append(heatLevels, mutedStyle)
+	}
+
+	statsBarStyle = lipgloss.NewStyle().Foreground(highlightColor)
+}
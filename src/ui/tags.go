@@ -0,0 +1,173 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	api "github.com/apooravm/tjournal/src/api"
+)
+
+const tagPanelWidth = 28
+
+type tagCount struct {
+	name  string
+	count int
+}
+
+// Sidebar listing every tag in the loaded logs along with how many logs use it.
+// Selected tags narrow the log list, either requiring all of them (AND) or any of them (OR).
+type tagPanel struct {
+	tags     []tagCount
+	selected map[string]bool
+	cursor   int
+	matchAll bool
+	focused  bool
+	// Older logs are still on the server, the counts may be short
+	partial bool
+}
+
+func newTagPanel() tagPanel {
+	return tagPanel{selected: make(map[string]bool)}
+}
+
+// Recount tags from the given logs. Selected tags that no longer exist are dropped.
+func (p *tagPanel) setLogs(logs *[]api.ReadJournalLogRes) {
+	counts := make(map[string]int)
+	if logs != nil {
+		for _, log := range *logs {
+			for _, tag := range log.Tags {
+				tag = strings.TrimSpace(tag)
+				if tag == "" {
+					continue
+				}
+				counts[tag]++
+			}
+		}
+	}
+
+	p.tags = p.tags[:0]
+	for name, count := range counts {
+		p.tags = append(p.tags, tagCount{name: name, count: count})
+	}
+
+	sort.Slice(p.tags, func(i, j int) bool {
+		if p.tags[i].count != p.tags[j].count {
+			return p.tags[i].count > p.tags[j].count
+		}
+		return p.tags[i].name < p.tags[j].name
+	})
+
+	for name := range p.selected {
+		if _, ok := counts[name]; !ok {
+			delete(p.selected, name)
+		}
+	}
+
+	p.cursor = min(p.cursor, max(len(p.tags)-1, 0))
+}
+
+func (p *tagPanel) moveCursor(delta int) {
+	if len(p.tags) == 0 {
+		return
+	}
+	p.cursor = min(max(p.cursor+delta, 0), len(p.tags)-1)
+}
+
+func (p *tagPanel) toggleCurrent() {
+	if len(p.tags) == 0 {
+		return
+	}
+	name := p.tags[p.cursor].name
+	if p.selected[name] {
+		delete(p.selected, name)
+	} else {
+		p.selected[name] = true
+	}
+}
+
+func (p *tagPanel) clear() {
+	for name := range p.selected {
+		delete(p.selected, name)
+	}
+}
+
+func (p tagPanel) active() bool {
+	return len(p.selected) > 0
+}
+
+// Whether a log with the given tags passes the current tag selection
+func (p tagPanel) matches(tags []string) bool {
+	if !p.active() {
+		return true
+	}
+
+	hits := 0
+	for _, tag := range tags {
+		if p.selected[strings.TrimSpace(tag)] {
+			hits++
+		}
+	}
+
+	if p.matchAll {
+		return hits >= len(p.selected)
+	}
+	return hits > 0
+}
+
+func (p tagPanel) modeName() string {
+	if p.matchAll {
+		return "AND"
+	}
+	return "OR"
+}
+
+func (p tagPanel) View(height int) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("Tags [%s]\n", p.modeName()))
+	header := 4
+	if p.partial {
+		b.WriteString(mutedStyle.Render("older logs may be missing") + "\n")
+		header++
+	}
+	b.WriteString("\n")
+
+	if len(p.tags) == 0 {
+		b.WriteString(mutedStyle.Render("No tags yet"))
+	}
+
+	// Keep the cursor in view when there are more tags than rows
+	rows := max(height-header, 1)
+	start := 0
+	if p.cursor >= rows {
+		start = p.cursor - rows + 1
+	}
+	end := min(start+rows, len(p.tags))
+
+	for i := start; i < end; i++ {
+		tag := p.tags[i]
+		check := "[ ]"
+		if p.selected[tag.name] {
+			check = "[x]"
+		}
+
+		line := fmt.Sprintf("%s %s (%d)", check, tag.name, tag.count)
+		switch {
+		case p.focused && i == p.cursor:
+			line = tagCursorStyle.Render("> " + line)
+		case p.selected[tag.name]:
+			line = tagSelectedStyle.Render("  " + line)
+		default:
+			line = "  " + line
+		}
+
+		b.WriteString(line + "\n")
+	}
+
+	style := tagPanelStyle
+	if p.focused {
+		style = tagPanelFocused
+	}
+	return style.Render(b.String())
+}